@@ -0,0 +1,141 @@
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/arachnid/ensdns/ens"
+	"github.com/arachnid/ensdns/failover"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// waitDeployed waits for tx to deploy the contract described by what, exiting
+// with an error if it doesn't.
+func waitDeployed(client *failover.Client, what string, tx *types.Transaction, err error) common.Address {
+	if err != nil {
+		fmt.Printf("Error deploying %s: %s\n", what, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Waiting for %s to be deployed by transaction %s\n", what, tx.Hash().Hex())
+
+	addr, err := bind.WaitDeployed(context.Background(), client, tx)
+	if err != nil {
+		fmt.Printf("Error deploying %s: %s\n", what, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Deployed %s at %s\n", what, addr.Hex())
+	return addr
+}
+
+// deploy sets up a new ENS deployment, for use on development chains: a
+// registry, a public resolver, DNS and RRset resolvers for zones to be uploaded
+// to, and optionally a first-in, first-served registrar that owns a top-level domain.
+func deploy(client *failover.Client, args []string) {
+	deployFlagSet.Parse(args)
+	args = deployFlagSet.Args()
+
+	if len(args) != 0 {
+		fmt.Println("usage: ensdns deploy [flags]")
+		os.Exit(1)
+	}
+
+	opts, err := deployFlags.transactOpts()
+	if err != nil {
+		fmt.Printf("Error getting account: %s\n", err)
+		os.Exit(1)
+	}
+
+	_, tx, err := ens.DeployRegistry(&opts, client)
+	registryAddress := waitDeployed(client, "registry", tx, err)
+
+	_, tx, err = ens.DeployPublicResolver(&opts, client, registryAddress)
+	waitDeployed(client, "public resolver", tx, err)
+
+	_, tx, err = ens.DeployDNSResolver(&opts, client, registryAddress)
+	waitDeployed(client, "DNS resolver", tx, err)
+
+	_, tx, err = ens.DeployRRSetResolver(&opts, client, registryAddress)
+	waitDeployed(client, "RRset resolver", tx, err)
+
+	tld := strings.Trim(*deployTLDFlag, ".")
+	if tld == "" {
+		return
+	}
+	_, tx, err = ens.DeployRegistrar(&opts, client, registryAddress, tld)
+	registrarAddress := waitDeployed(client, "registrar for "+tld, tx, err)
+
+	registry, err := ens.New(client, registryAddress, opts)
+	if err != nil {
+		fmt.Printf("Error constructing ENS instance: %v\n", err)
+		os.Exit(1)
+	}
+	tx, err = registry.SetSubnodeOwner("", tld, registrarAddress)
+	deployFlags.finish(client, tx, err)
+}
+
+// register registers a name with the registrar that owns its parent.
+func register(client *failover.Client, args []string) {
+	registerFlagSet.Parse(args)
+	args = registerFlagSet.Args()
+
+	if len(args) != 1 || !strings.Contains(strings.Trim(args[0], "."), ".") {
+		fmt.Println("usage: ensdns register [flags] <label>.<parent>")
+		os.Exit(1)
+	}
+	name := dns.Fqdn(args[0])
+	parts := strings.SplitN(name, ".", 2)
+	label, parent := parts[0], parts[1]
+
+	registry, opts := registerFlags.openRegistry(client, parent)
+
+	owner := opts.From
+	if *registerOwnerFlag != "" {
+		owner = parseAddressArg(*registerOwnerFlag)
+	}
+
+	// Registrars only let the current owner re-register a name
+	current, err := registry.GetOwner(name)
+	if err != nil {
+		fmt.Printf("Error getting owner: %s\n", err)
+		os.Exit(1)
+	}
+	if current != (common.Address{}) && current != opts.From {
+		fmt.Printf("Name %s is already owned by %s\n", name, current.Hex())
+		os.Exit(1)
+	}
+
+	registrarAddress, err := registry.GetOwner(parent)
+	if err != nil {
+		fmt.Printf("Error getting registrar: %s\n", err)
+		os.Exit(1)
+	}
+	registrar, err := ens.NewRegistrar(client, registrarAddress, opts)
+	if err != nil {
+		fmt.Printf("Error constructing registrar instance: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Registering %s to %s with registrar %s\n", name, owner.Hex(), registrarAddress.Hex())
+	tx, err := registrar.Register(label, owner)
+	registerFlags.finish(client, tx, err)
+}
@@ -0,0 +1,204 @@
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/arachnid/ensdns/ens"
+	"github.com/arachnid/ensdns/failover"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/miekg/dns"
+)
+
+// rrsetKey identifies an RRset within a zone.
+type rrsetKey struct {
+	name   string // Lower-cased, fully qualified
+	rrtype uint16
+	class  uint16
+}
+
+func (key rrsetKey) String() string {
+	return fmt.Sprintf("%s %s %s", key.name, dns.ClassToString[key.class], dns.TypeToString[key.rrtype])
+}
+
+// groupRRSets groups rrs by name, type and class.
+func groupRRSets(rrs []dns.RR) map[rrsetKey][]dns.RR {
+	sets := make(map[rrsetKey][]dns.RR)
+	for _, rr := range rrs {
+		hdr := rr.Header()
+		key := rrsetKey{strings.ToLower(dns.Fqdn(hdr.Name)), hdr.Rrtype, hdr.Class}
+		sets[key] = append(sets[key], rr)
+	}
+	return sets
+}
+
+// rrsetChange describes an RRset that differs between two versions of a zone.
+// Old is empty for added RRsets and New is empty for removed ones.
+type rrsetChange struct {
+	key rrsetKey
+	old []dns.RR
+	new []dns.RR
+}
+
+func (c rrsetChange) kind() string {
+	switch {
+	case len(c.old) == 0:
+		return "added"
+	case len(c.new) == 0:
+		return "removed"
+	default:
+		return "changed"
+	}
+}
+
+// canonicalRRs returns the text form of each record in rrs in sorted order, with
+// owner names lower-cased, so equal RRsets compare equal.
+func canonicalRRs(rrs []dns.RR) []string {
+	ret := make([]string, len(rrs))
+	for i, rr := range rrs {
+		rr = dns.Copy(rr)
+		rr.Header().Name = strings.ToLower(dns.Fqdn(rr.Header().Name))
+		ret[i] = rr.String()
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func equalRRSets(a, b []dns.RR) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	ca, cb := canonicalRRs(a), canonicalRRs(b)
+	for i := range ca {
+		if ca[i] != cb[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// diffRRSets returns the RRsets that must change to turn old into new, sorted
+// by name, type and class.
+func diffRRSets(old, new []dns.RR) []rrsetChange {
+	oldSets, newSets := groupRRSets(old), groupRRSets(new)
+
+	var changes []rrsetChange
+	for key, rrs := range newSets {
+		if !equalRRSets(oldSets[key], rrs) {
+			changes = append(changes, rrsetChange{key, oldSets[key], rrs})
+		}
+	}
+	for key, rrs := range oldSets {
+		if _, ok := newSets[key]; !ok {
+			changes = append(changes, rrsetChange{key, rrs, nil})
+		}
+	}
+
+	sort.Sort(byKey(changes))
+	return changes
+}
+
+// byKey sorts changes by name, type and class.
+type byKey []rrsetChange
+
+func (c byKey) Len() int      { return len(c) }
+func (c byKey) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c byKey) Less(i, j int) bool {
+	if c[i].key.name != c[j].key.name {
+		return c[i].key.name < c[j].key.name
+	}
+	if c[i].key.rrtype != c[j].key.rrtype {
+		return c[i].key.rrtype < c[j].key.rrtype
+	}
+	return c[i].key.class < c[j].key.class
+}
+
+// printChanges prints changes in a unified diff-like format.
+func printChanges(changes []rrsetChange) {
+	for _, c := range changes {
+		fmt.Printf("%s (%s)\n", c.key, c.kind())
+		for _, rr := range canonicalRRs(c.old) {
+			fmt.Printf("- %s\n", rr)
+		}
+		for _, rr := range canonicalRRs(c.new) {
+			fmt.Printf("+ %s\n", rr)
+		}
+	}
+}
+
+// diff compares a zonefile with the records stored in ENS, showing the changes
+// an upload of the zonefile would make. Like diff(1), it exits with status 1 if
+// they differ and 2 if they can't be compared.
+func diff(client *failover.Client, args []string) {
+	diffFlagSet.Parse(args)
+	args = diffFlagSet.Args()
+
+	if len(args) != 1 {
+		fmt.Println("usage: ensdns diff <filename>")
+		os.Exit(2)
+	}
+
+	rrs, soa, err := readRRs(args[0])
+	if err != nil {
+		fmt.Printf("Error reading zonefile: %s\n", err)
+		os.Exit(2)
+	}
+
+	registryAddress, err := registryFromNS(soa.Ns)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(2)
+	}
+
+	registry, err := ens.New(client, registryAddress, bind.TransactOpts{})
+	if err != nil {
+		fmt.Printf("Error constructing ENS instance: %v\n", err)
+		os.Exit(2)
+	}
+
+	resolver, err := registry.GetResolver(soa.Hdr.Name)
+	if err != nil {
+		fmt.Printf("Error getting resolver: %s\n", err)
+		os.Exit(2)
+	}
+
+	var current []dns.RR
+	if resolver.Address != (common.Address{}) {
+		current, err = resolver.GetRRs()
+		if err != nil {
+			fmt.Printf("Error getting current RRs: %s\n", err)
+			os.Exit(2)
+		}
+	}
+
+	changes := diffRRSets(current, rrs)
+	if len(changes) == 0 {
+		return
+	}
+
+	printChanges(changes)
+	counts := make(map[string]int)
+	for _, c := range changes {
+		counts[c.kind()]++
+	}
+	fmt.Printf("%d RRsets added, %d removed, %d changed\n", counts["added"], counts["removed"], counts["changed"])
+	os.Exit(1)
+}
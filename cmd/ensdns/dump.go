@@ -0,0 +1,109 @@
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/arachnid/ensdns/ens"
+	"github.com/arachnid/ensdns/failover"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// sortRRs sorts rrs into the order they're written to a zonefile: the SOA
+// first, followed by the remaining records ordered by name, type and data.
+func sortRRs(rrs []dns.RR) {
+	sort.Stable(zonefileOrder(rrs))
+}
+
+type zonefileOrder []dns.RR
+
+func (rrs zonefileOrder) Len() int      { return len(rrs) }
+func (rrs zonefileOrder) Swap(i, j int) { rrs[i], rrs[j] = rrs[j], rrs[i] }
+func (rrs zonefileOrder) Less(i, j int) bool {
+	a, b := rrs[i].Header(), rrs[j].Header()
+	if (a.Rrtype == dns.TypeSOA) != (b.Rrtype == dns.TypeSOA) {
+		return a.Rrtype == dns.TypeSOA
+	}
+	if an, bn := strings.ToLower(a.Name), strings.ToLower(b.Name); an != bn {
+		return an < bn
+	}
+	if a.Rrtype != b.Rrtype {
+		return a.Rrtype < b.Rrtype
+	}
+	return rrs[i].String() < rrs[j].String()
+}
+
+func dump(client *failover.Client, args []string) {
+	dumpFlagSet.Parse(args)
+	args = dumpFlagSet.Args()
+
+	if len(args) != 1 {
+		fmt.Println("usage: ensdns dump [flags] <name>")
+		os.Exit(1)
+	}
+	name := dns.Fqdn(args[0])
+
+	var registryAddress common.Address
+	if *dumpRegistryFlag != "" {
+		if !common.IsHexAddress(*dumpRegistryFlag) {
+			fmt.Println("-registry must be a hex address")
+			os.Exit(1)
+		}
+		registryAddress = common.HexToAddress(*dumpRegistryFlag)
+	} else {
+		var err error
+		registryAddress, _, err = findRegistry(name)
+		if err != nil {
+			fmt.Printf("Error finding registry for %s: %s\n", name, err)
+			os.Exit(1)
+		}
+	}
+
+	registry, err := ens.New(client, registryAddress, bind.TransactOpts{})
+	if err != nil {
+		fmt.Printf("Error constructing ENS instance: %v\n", err)
+		os.Exit(1)
+	}
+
+	record, err := registry.Lookup(context.Background(), name, common.Address{})
+	if err == ens.NoResolverError {
+		fmt.Printf("No resolver set for name %s\n", name)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Printf("Error looking up %s: %s\n", name, err)
+		os.Exit(1)
+	}
+
+	if *dumpMetadataFlag {
+		fmt.Printf("; registry %s\n", registryAddress.Hex())
+		fmt.Printf("; owner %s\n", record.Owner.Hex())
+		fmt.Printf("; resolver %s\n", record.Resolver.Address.Hex())
+		fmt.Printf("; ttl %d\n", record.TTL)
+	}
+	fmt.Printf("$ORIGIN %s\n", name)
+	sortRRs(record.RRs)
+	for _, rr := range record.RRs {
+		fmt.Println(rr.String())
+	}
+}
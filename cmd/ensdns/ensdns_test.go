@@ -0,0 +1,569 @@
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/arachnid/ensdns/ens"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// simBackend adapts the simulated backend to the server, tracking the number of
+// the latest block, which the simulated backend doesn't expose. If hold is set,
+// requests for the latest header signal held and then wait for hold to be closed.
+type simBackend struct {
+	*backends.SimulatedBackend
+	block int64
+	hold  chan struct{}
+	held  chan struct{}
+}
+
+func (b *simBackend) Commit() {
+	b.SimulatedBackend.Commit()
+	atomic.AddInt64(&b.block, 1)
+}
+
+func (b *simBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		if b.hold != nil {
+			b.held <- struct{}{}
+			select {
+			case <-b.hold:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		number = big.NewInt(atomic.LoadInt64(&b.block))
+	}
+	return &types.Header{Number: new(big.Int).Set(number)}, nil
+}
+
+// BatchCallContext executes each eth_call in a batch in turn, so the server
+// looks up zones with batched calls as it does with real nodes.
+func (b *simBackend) BatchCallContext(ctx context.Context, batch []rpc.BatchElem) error {
+	for i := range batch {
+		elem := &batch[i]
+		if elem.Method != "eth_call" {
+			elem.Error = fmt.Errorf("Unsupported method %s", elem.Method)
+			continue
+		}
+		args := elem.Args[0].(map[string]interface{})
+		to := args["to"].(common.Address)
+		msg := ethereum.CallMsg{To: &to, Data: args["data"].(hexutil.Bytes)}
+		var block *big.Int
+		if arg := elem.Args[1].(string); arg != "latest" {
+			if block, elem.Error = hexutil.DecodeBig(arg); elem.Error != nil {
+				continue
+			}
+		}
+		out, err := b.CallContract(ctx, msg, block)
+		if err != nil {
+			elem.Error = err
+			continue
+		}
+		*elem.Result.(*hexutil.Bytes) = out
+	}
+	return nil
+}
+
+// harness runs the whole pipeline in-process: an ENS registry and DNS resolver
+// on a simulated chain, a fake root server that delegates uploaded zones to the
+// registry, and an ENSDNS server answering queries for them.
+type harness struct {
+	t         *testing.T
+	backend   *simBackend
+	auth      *bind.TransactOpts
+	registry  *ens.Registry
+	resolver  common.Address
+	root      *dns.Server
+	ensdns    *ENSDNS
+	server    *dns.Server
+	addr      string
+	oldRoots  []string
+	mu        sync.Mutex
+	delegated map[string]bool
+}
+
+func newHarness(t *testing.T) *harness {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+	auth := bind.NewKeyedTransactor(key)
+	h := &harness{
+		t:         t,
+		backend:   &simBackend{SimulatedBackend: backends.NewSimulatedBackend(core.GenesisAccount{Address: auth.From, Balance: big.NewInt(1e18)})},
+		auth:      auth,
+		delegated: make(map[string]bool),
+	}
+
+	registryAddress, _, err := ens.DeployRegistry(auth, h.backend)
+	if err != nil {
+		t.Fatalf("Error deploying registry: %s", err)
+	}
+	h.backend.Commit()
+	h.resolver, _, err = ens.DeployDNSResolver(auth, h.backend, registryAddress)
+	if err != nil {
+		t.Fatalf("Error deploying DNS resolver: %s", err)
+	}
+	h.backend.Commit()
+	h.registry, err = ens.New(h.backend, registryAddress, *auth)
+	if err != nil {
+		t.Fatalf("Error constructing ENS instance: %s", err)
+	}
+
+	var rootAddr string
+	h.root, rootAddr = startServer(t, dns.HandlerFunc(h.handleRoot))
+	h.oldRoots = rootServers
+	rootServers = []string{rootAddr}
+
+	arc, err := lru.NewARC(16)
+	if err != nil {
+		t.Fatalf("Error creating ARC cache: %s", err)
+	}
+	h.ensdns = &ENSDNS{
+		client:       h.backend,
+		cache:        arc,
+		queryTimeout: 5 * time.Second,
+		prefetching:  make(map[zoneCacheKey]bool),
+	}
+	h.server, h.addr = startServer(t, dns.HandlerFunc(h.ensdns.Handle))
+	return h
+}
+
+func (h *harness) close() {
+	h.server.Shutdown()
+	h.root.Shutdown()
+	rootServers = h.oldRoots
+}
+
+// startServer serves handler on a free local UDP port, returning the server and
+// its address.
+func startServer(t *testing.T, handler dns.Handler) (*dns.Server, string) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening: %s", err)
+	}
+	started := make(chan struct{})
+	server := &dns.Server{PacketConn: conn, Handler: handler, NotifyStartedFunc: func() { close(started) }}
+	go server.ActivateAndServe()
+	<-started
+	return server, conn.LocalAddr().String()
+}
+
+// nameserver returns the name of the ENS nameserver for the harness's registry.
+func (h *harness) nameserver() string {
+	return strings.ToLower(h.registry.Address.Hex()[2:]) + *nsDomainFlag
+}
+
+// handleRoot answers NS queries as a root server would, referring names within
+// uploaded zones to the ENS nameserver and denying that any others exist.
+func (h *harness) handleRoot(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	name := strings.ToLower(r.Question[0].Name)
+	h.mu.Lock()
+	for apex := range h.delegated {
+		if dns.IsSubDomain(apex, name) {
+			m.Ns = append(m.Ns, &dns.NS{
+				Hdr: dns.RR_Header{Name: apex, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: 300},
+				Ns:  h.nameserver(),
+			})
+		}
+	}
+	h.mu.Unlock()
+
+	if len(m.Ns) == 0 {
+		m.Rcode = dns.RcodeNameError
+		m.Ns = []dns.RR{&dns.SOA{
+			Hdr:     dns.RR_Header{Name: ".", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 60},
+			Ns:      "a.root-servers.net.",
+			Mbox:    "nstld.verisign-grs.com.",
+			Refresh: 1800,
+			Retry:   900,
+			Expire:  604800,
+			Minttl:  60,
+		}}
+	}
+	w.WriteMsg(m)
+}
+
+// register makes the harness's account the owner of name, registering each of
+// its ancestors that it doesn't already own, and sets its resolver to the DNS
+// resolver.
+func (h *harness) register(name string) {
+	labels := dns.SplitDomainName(name)
+	parent := ""
+	for i := len(labels) - 1; i >= 0; i-- {
+		node := dns.Fqdn(strings.Join(labels[i:], "."))
+		owner, err := h.registry.GetOwner(node)
+		if err != nil {
+			h.t.Fatalf("Error getting owner of %s: %s", node, err)
+		}
+		if owner != h.auth.From {
+			if _, err := h.registry.SetSubnodeOwner(parent, labels[i], h.auth.From); err != nil {
+				h.t.Fatalf("Error registering %s: %s", node, err)
+			}
+			h.backend.Commit()
+		}
+		parent = node
+	}
+
+	if _, err := h.registry.SetResolver(name, h.resolver); err != nil {
+		h.t.Fatalf("Error setting resolver for %s: %s", name, err)
+	}
+	h.backend.Commit()
+}
+
+// upload reads a zonefile for apex with the given SOA refresh interval and
+// records, as the upload command does, stores it in ENS and delegates apex to
+// the ENS nameserver.
+func (h *harness) upload(apex string, refresh uint32, records ...string) {
+	f, err := ioutil.TempFile("", "ensdns")
+	if err != nil {
+		h.t.Fatalf("Error creating zonefile: %s", err)
+	}
+	defer os.Remove(f.Name())
+	fmt.Fprintf(f, "$ORIGIN %s\n", apex)
+	fmt.Fprintf(f, "@ 300 IN SOA %s hostmaster 1 %d 60 3600 60\n", h.nameserver(), refresh)
+	for _, record := range records {
+		fmt.Fprintln(f, record)
+	}
+	f.Close()
+
+	rrs, _, err := readRRs(f.Name())
+	if err != nil {
+		h.t.Fatalf("Error reading zonefile: %s", err)
+	}
+	h.setZone(apex, rrs)
+}
+
+// setZone stores rrs in ENS as the records for apex, and delegates apex to the
+// ENS nameserver.
+func (h *harness) setZone(apex string, rrs []dns.RR) {
+	h.register(apex)
+	resolver, err := h.registry.GetResolver(apex)
+	if err != nil {
+		h.t.Fatalf("Error getting resolver for %s: %s", apex, err)
+	}
+	txs, err := resolver.SetRRs(rrs)
+	if err != nil {
+		h.t.Fatalf("Error uploading %s: %s", apex, err)
+	}
+	h.backend.Commit()
+	for _, tx := range txs {
+		receipt, err := h.backend.TransactionReceipt(context.Background(), tx.Hash())
+		if err != nil {
+			h.t.Fatalf("Error getting receipt: %s", err)
+		}
+		if receipt.GasUsed.Cmp(tx.Gas()) >= 0 {
+			h.t.Fatalf("Upload transaction %s for %s failed", tx.Hash().Hex(), apex)
+		}
+	}
+
+	h.delegate(apex)
+}
+
+// delegate makes the fake root server refer apex to the ENS nameserver.
+func (h *harness) delegate(apex string) {
+	h.mu.Lock()
+	h.delegated[strings.ToLower(apex)] = true
+	h.mu.Unlock()
+}
+
+// query sends a question to the ENSDNS server.
+func (h *harness) query(name string, qtype uint16) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(name, qtype)
+	client := &dns.Client{ReadTimeout: 5 * time.Second}
+	r, _, err := client.Exchange(m, h.addr)
+	if err != nil {
+		h.t.Fatalf("Error querying %s %s: %s", name, dns.TypeToString[qtype], err)
+	}
+	return r
+}
+
+// checkAnswer checks that r is an authoritative answer consisting of the records
+// in want.
+func checkAnswer(t *testing.T, r *dns.Msg, want ...string) {
+	q := r.Question[0]
+	if r.Rcode != dns.RcodeSuccess {
+		t.Errorf("%s %s: got rcode %s, want NOERROR", q.Name, dns.TypeToString[q.Qtype], dns.RcodeToString[r.Rcode])
+		return
+	}
+	if !r.Authoritative {
+		t.Errorf("%s %s: answer is not authoritative", q.Name, dns.TypeToString[q.Qtype])
+	}
+
+	var wantRRs []dns.RR
+	for _, s := range want {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatalf("Error parsing %q: %s", s, err)
+		}
+		wantRRs = append(wantRRs, rr)
+	}
+	if !equalRRSets(r.Answer, wantRRs) {
+		t.Errorf("%s %s: got answer %v, want %v", q.Name, dns.TypeToString[q.Qtype], canonicalRRs(r.Answer), canonicalRRs(wantRRs))
+	}
+}
+
+func TestServeUploadedZone(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+
+	h.upload("example.", 3600,
+		"@ 300 IN A 192.0.2.1",
+		"@ 300 IN MX 10 mail",
+		"@ 300 IN TXT \"hello world\"",
+		"mail 300 IN A 192.0.2.2",
+		"mail 300 IN A 192.0.2.3",
+		"www 300 IN CNAME @",
+		"*.users 300 IN TXT \"wildcard\"",
+	)
+
+	for _, test := range []struct {
+		name  string
+		qtype uint16
+		want  []string
+	}{
+		{"example.", dns.TypeA, []string{"example. 300 IN A 192.0.2.1"}},
+		{"example.", dns.TypeMX, []string{"example. 300 IN MX 10 mail.example."}},
+		{"example.", dns.TypeTXT, []string{"example. 300 IN TXT \"hello world\""}},
+		{"MAIL.Example.", dns.TypeA, []string{"mail.example. 300 IN A 192.0.2.2", "mail.example. 300 IN A 192.0.2.3"}},
+		{"www.example.", dns.TypeA, []string{"www.example. 300 IN CNAME example."}},
+		{"alice.users.example.", dns.TypeTXT, []string{"alice.users.example. 300 IN TXT \"wildcard\""}},
+		{"missing.example.", dns.TypeA, nil},
+		{"mail.example.", dns.TypeAAAA, nil},
+	} {
+		checkAnswer(t, h.query(test.name, test.qtype), test.want...)
+	}
+}
+
+func TestServeSeveralZones(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+
+	h.upload("one.", 3600, "@ 300 IN A 192.0.2.1")
+	h.upload("two.example.", 3600, "@ 300 IN A 198.51.100.1", "sub 300 IN A 198.51.100.2")
+
+	checkAnswer(t, h.query("one.", dns.TypeA), "one. 300 IN A 192.0.2.1")
+	checkAnswer(t, h.query("two.example.", dns.TypeA), "two.example. 300 IN A 198.51.100.1")
+	checkAnswer(t, h.query("sub.two.example.", dns.TypeA), "sub.two.example. 300 IN A 198.51.100.2")
+}
+
+func TestServeUpdatedZone(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+
+	// A refresh interval of zero stops the server caching the zone
+	h.upload("example.", 0, "@ 300 IN A 192.0.2.1")
+	checkAnswer(t, h.query("example.", dns.TypeA), "example. 300 IN A 192.0.2.1")
+
+	h.upload("example.", 0, "@ 300 IN A 192.0.2.2", "new 300 IN A 192.0.2.3")
+	checkAnswer(t, h.query("example.", dns.TypeA), "example. 300 IN A 192.0.2.2")
+	checkAnswer(t, h.query("new.example.", dns.TypeA), "new.example. 300 IN A 192.0.2.3")
+}
+
+func TestRefuseUndelegatedName(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+
+	h.upload("example.", 3600, "@ 300 IN A 192.0.2.1")
+
+	r := h.query("example.invalid.", dns.TypeA)
+	if r.Rcode != dns.RcodeRefused {
+		t.Errorf("Got rcode %s for undelegated name, want REFUSED", dns.RcodeToString[r.Rcode])
+	}
+}
+
+func TestFailZoneWithoutSOA(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+
+	rr, err := dns.NewRR("example. 300 IN A 192.0.2.1")
+	if err != nil {
+		t.Fatalf("Error parsing record: %s", err)
+	}
+	h.setZone("example.", []dns.RR{rr})
+	h.ensdns.negativeTTL = time.Hour
+
+	r := h.query("example.", dns.TypeA)
+	if r.Authoritative || len(r.Answer) != 0 {
+		t.Errorf("Got authoritative=%v answer %v for zone without SOA, want no answer", r.Authoritative, r.Answer)
+	}
+
+	// The failure is cached, so the zone isn't fetched again straight away
+	h.upload("example.", 3600, "@ 300 IN A 192.0.2.1")
+	r = h.query("example.", dns.TypeA)
+	if r.Authoritative || len(r.Answer) != 0 {
+		t.Errorf("Got authoritative=%v answer %v within negative TTL, want no answer", r.Authoritative, r.Answer)
+	}
+}
+
+func TestServeChunkedZone(t *testing.T) {
+	defer func(size int) { ens.MaxChunkSize = size }(ens.MaxChunkSize)
+	ens.MaxChunkSize = 512
+
+	h := newHarness(t)
+	defer h.close()
+
+	var records []string
+	for i := 0; i < 40; i++ {
+		records = append(records, fmt.Sprintf("r%d 300 IN TXT \"record number %d of the zone\"", i, i))
+	}
+	h.upload("example.", 3600, records...)
+
+	checkAnswer(t, h.query("r0.example.", dns.TypeTXT), "r0.example. 300 IN TXT \"record number 0 of the zone\"")
+	checkAnswer(t, h.query("r39.example.", dns.TypeTXT), "r39.example. 300 IN TXT \"record number 39 of the zone\"")
+}
+
+func TestServeZoneWithNewResolver(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+
+	// A refresh interval of zero makes the server fetch the zone again, using
+	// the resolver it found last time as a hint
+	h.upload("example.", 0, "@ 300 IN A 192.0.2.1")
+	checkAnswer(t, h.query("example.", dns.TypeA), "example. 300 IN A 192.0.2.1")
+
+	resolver, _, err := ens.DeployDNSResolver(h.auth, h.backend, h.registry.Address)
+	if err != nil {
+		t.Fatalf("Error deploying DNS resolver: %s", err)
+	}
+	h.backend.Commit()
+	h.resolver = resolver
+	h.upload("example.", 0, "@ 300 IN A 192.0.2.2")
+	checkAnswer(t, h.query("example.", dns.TypeA), "example. 300 IN A 192.0.2.2")
+}
+
+func TestFailNameWithoutResolver(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+
+	h.register("example.")
+	if _, err := h.registry.SetResolver("example.", common.Address{}); err != nil {
+		t.Fatalf("Error clearing resolver: %s", err)
+	}
+	h.backend.Commit()
+	h.delegate("example.")
+
+	r := h.query("example.", dns.TypeA)
+	if r.Authoritative || len(r.Answer) != 0 {
+		t.Errorf("Got authoritative=%v answer %v for name without resolver, want no answer", r.Authoritative, r.Answer)
+	}
+}
+
+func TestSharedFetchOutlivesCaller(t *testing.T) {
+	h := newHarness(t)
+	defer h.close()
+
+	h.upload("example.", 3600, "@ 300 IN A 192.0.2.1")
+	h.backend.hold = make(chan struct{})
+	h.backend.held = make(chan struct{}, 1)
+
+	// The first caller gives up while the fetch it started is in flight
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := h.ensdns.getZone(ctx, "example.")
+		first <- err
+	}()
+	<-h.backend.held
+
+	second := make(chan error)
+	go func() {
+		_, err := h.ensdns.getZone(context.Background(), "example.")
+		second <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	if err := <-first; err != context.Canceled {
+		t.Errorf("Got error %v for cancelled caller, want %v", err, context.Canceled)
+	}
+
+	close(h.backend.hold)
+	if err := <-second; err != nil {
+		t.Errorf("Error getting zone after another caller gave up: %s", err)
+	}
+}
+
+func TestPruneZoneStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ensdns-store")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := openZoneStore(dir)
+	if err != nil {
+		t.Fatalf("Error opening zone store: %s", err)
+	}
+	defer store.Close()
+
+	arc, err := lru.NewARC(16)
+	if err != nil {
+		t.Fatalf("Error creating ARC cache: %s", err)
+	}
+	ed := &ENSDNS{cache: arc, store: store}
+
+	registry := common.HexToAddress("0x1234")
+	expires := time.Now().Add(time.Hour)
+	for _, zone := range []storedZone{
+		{Registry: registry, Root: "cached.", Expires: expires},
+		{Registry: registry, Root: "evicted.", Expires: expires},
+		{Registry: registry, Root: "expired.", Expires: time.Now().Add(-time.Hour)},
+	} {
+		if err := store.Put(zone); err != nil {
+			t.Fatalf("Error storing zone %s: %s", zone.Root, err)
+		}
+	}
+	arc.Add(zoneCacheKey{registry, "cached."}, zoneCacheEntry{})
+
+	if err := ed.pruneStore(); err != nil {
+		t.Fatalf("Error pruning zone store: %s", err)
+	}
+	var roots []string
+	if err := store.ForEach(func(zone storedZone) { roots = append(roots, zone.Root) }); err != nil {
+		t.Fatalf("Error reading zone store: %s", err)
+	}
+	if len(roots) != 1 || roots[0] != "cached." {
+		t.Errorf("Got zones %v after pruning, want [cached.]", roots)
+	}
+}
@@ -24,33 +24,77 @@ import (
 	"math/big"
 	"math/rand"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/arachnid/ensdns/ens"
+	"github.com/arachnid/ensdns/failover"
 	"github.com/arachnid/ensdns/utils"
-	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
-	ethutils "github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+	"golang.org/x/sync/singleflight"
 )
 
 var (
-	ethapiFlag          = flag.String("ethapi", "http://localhost:8545", "Path to connect to Ethereum node on")
+	ethapiFlag          = flag.String("ethapi", "http://localhost:8545", "Comma-separated list of Ethereum node endpoints (HTTP, WebSocket or IPC) to connect to")
+	ethTimeoutFlag      = flag.Duration("ethtimeout", 5 * time.Second, "Timeout for each call to an Ethereum endpoint")
 	nsDomainFlag        = flag.String("nsdomain", ".ens.domains.", "Domain name for this ENS server")
 
 	uploadFlagSet       = flag.NewFlagSet("upload", flag.ExitOnError)
-	uploadKeystoreFlag  = uploadFlagSet.String("keystore", "", "Path to keystore")
-	uploadAccountFlag   = uploadFlagSet.String("account", "0", "Account to use to send transactions")
-	uploadPasswordFlag  = uploadFlagSet.String("password", "", "Password to unlock account with")
+	uploadSignerFlags   = newSignerFlags(uploadFlagSet)
+	uploadGasLimitFlag  = uploadFlagSet.Int64("gaslimit", 0, "Gas limit for each transaction (0 to estimate)")
+	uploadConfirmationsFlag = uploadFlagSet.Int64("confirmations", 1, "Number of blocks to wait for, including the one that mines the last transaction, before verifying the upload (0 to not wait)")
+	uploadDryRunFlag    = uploadFlagSet.Bool("dry-run", false, "Show the changes and estimated gas without sending any transactions")
+	uploadUnsignedOutFlag = uploadFlagSet.String("unsigned-out", "", "Write the transactions to a JSON array to be signed elsewhere, one eth_signTransaction request per entry, instead of sending them")
+	uploadCalldataFlag  = uploadFlagSet.Bool("calldata", false, "Print the target and calldata of each transaction, for an owning contract such as a multisig wallet to send, instead of sending them; pass the contract's address as -account")
+	uploadProposalOutFlag = uploadFlagSet.String("proposal-out", "", "Also write the calls to a JSON file for import into a multisig wallet (implies -calldata)")
+
+	dumpFlagSet         = flag.NewFlagSet("dump", flag.ExitOnError)
+	dumpRegistryFlag    = dumpFlagSet.String("registry", "", "Address of the ENS registry (default: found by following the name's delegation)")
+	dumpMetadataFlag    = dumpFlagSet.Bool("metadata", false, "Include the name's registry owner, resolver and TTL as comments")
+
+	diffFlagSet         = flag.NewFlagSet("diff", flag.ExitOnError)
+
+	queryFlagSet        = flag.NewFlagSet("query", flag.ExitOnError)
+	queryConfirmationsFlag = queryFlagSet.Int64("confirmations", 0, "Number of blocks behind the latest to read the zone at, as for serve")
+	queryDeadlineFlag   = queryFlagSet.Duration("timeout", 30 * time.Second, "Maximum time to spend fetching records")
+
+	setOwnerFlagSet     = flag.NewFlagSet("set-owner", flag.ExitOnError)
+	setOwnerFlags       = newRegistryFlags(setOwnerFlagSet)
+	setSubnodeOwnerFlagSet = flag.NewFlagSet("set-subnode-owner", flag.ExitOnError)
+	setSubnodeOwnerFlags = newRegistryFlags(setSubnodeOwnerFlagSet)
+	setResolverFlagSet  = flag.NewFlagSet("set-resolver", flag.ExitOnError)
+	setResolverFlags    = newRegistryFlags(setResolverFlagSet)
+	setTTLFlagSet       = flag.NewFlagSet("set-ttl", flag.ExitOnError)
+	setTTLFlags         = newRegistryFlags(setTTLFlagSet)
+
+	deployFlagSet       = flag.NewFlagSet("deploy", flag.ExitOnError)
+	deployFlags         = newTxFlags(deployFlagSet)
+	deployTLDFlag       = deployFlagSet.String("tld", "eth", "Top-level domain to deploy a first-in, first-served registrar for (empty for none)")
+	registerFlagSet     = flag.NewFlagSet("register", flag.ExitOnError)
+	registerFlags       = newRegistryFlags(registerFlagSet)
+	registerOwnerFlag   = registerFlagSet.String("owner", "", "Address to register the name to (default: the sending account)")
+
+	broadcastFlagSet    = flag.NewFlagSet("broadcast", flag.ExitOnError)
+	broadcastConfirmationsFlag = broadcastFlagSet.Int64("confirmations", 1, "Number of blocks to wait for, including the one that mines the last transaction (0 to not wait)")
 
 	serveFlagSet		= flag.NewFlagSet("serve", flag.ExitOnError)
 	listenAddressFlag   = serveFlagSet.String("address", ":53", "Local address and port to serve on")
+	healthIntervalFlag  = serveFlagSet.Duration("healthinterval", 15 * time.Second, "Interval between health checks of Ethereum endpoints")
+	queryTimeoutFlag    = serveFlagSet.Duration("querytimeout", 5 * time.Second, "Maximum time to spend fetching records to answer a query")
 	cacheSizeFlag       = serveFlagSet.Int("cachesize", 65536, "Maximum number of zones to cache")
+	cacheDirFlag        = serveFlagSet.String("cachedir", "", "Directory to persist fetched zones in across restarts (empty to disable)")
+	cachePruneFlag      = serveFlagSet.Duration("cacheprune", time.Minute, "Interval between deleting expired and evicted zones from -cachedir")
+	confirmationsFlag   = serveFlagSet.Int64("confirmations", 0, "Number of blocks behind the latest to read zones at, to avoid serving records that are reorged away")
+	negativeTTLFlag     = serveFlagSet.Duration("negativettl", 30 * time.Second, "How long to cache failures to load a zone, such as a missing SOA record or resolver")
+	prefetchFlag        = serveFlagSet.Float64("prefetch", 0.1, "Fraction of a zone's cache lifetime remaining at which to refresh it in the background (0 to disable)")
 
 	rootServers = []string{
 		"a.root-servers.net",
@@ -72,7 +116,7 @@ var (
 func main() {
 	flag.Parse()
 
-	client, err := ethclient.Dial(*ethapiFlag)
+	client, err := failover.Dial(strings.Split(*ethapiFlag, ","), *ethTimeoutFlag)
 	if err != nil {
 		log.Fatalf("Error connecting to Ethereum API: %v", err)
 	}
@@ -83,6 +127,16 @@ func main() {
 		fmt.Println("Commands include:")
 		fmt.Println("  serve <address>   Start DNS server listening on <address>")
 		fmt.Println("  upload <filename> Upload the provided zonefile to ENS")
+		fmt.Println("  broadcast <filename> Send transactions signed offline")
+		fmt.Println("  dump <name>       Print the zone stored in ENS for <name> as a zonefile")
+		fmt.Println("  diff <filename>   Compare the provided zonefile with the zone stored in ENS")
+		fmt.Println("  query <name> [type] Resolve a query as the server would, showing each step")
+		fmt.Println("  set-owner <name> <owner> Transfer ownership of <name> in ENS")
+		fmt.Println("  set-subnode-owner <name> <label> <owner> Set the owner of <label>.<name> in ENS")
+		fmt.Println("  set-resolver <name> <resolver> Set the resolver for <name> in ENS")
+		fmt.Println("  set-ttl <name> <ttl> Set the TTL for <name> in ENS")
+		fmt.Println("  deploy            Deploy a new ENS registry, resolvers and registrar")
+		fmt.Println("  register <name>   Register <name> with the registrar for its parent")
 		os.Exit(1)
 	}
 
@@ -91,37 +145,29 @@ func main() {
 		upload(client, args[1:])
 	case "serve":
 		serve(client, args[1:])
+	case "broadcast":
+		broadcast(client, args[1:])
+	case "dump":
+		dump(client, args[1:])
+	case "diff":
+		diff(client, args[1:])
+	case "query":
+		query(client, args[1:])
+	case "set-owner":
+		setOwner(client, args[1:])
+	case "set-subnode-owner":
+		setSubnodeOwner(client, args[1:])
+	case "set-resolver":
+		setResolver(client, args[1:])
+	case "set-ttl":
+		setTTL(client, args[1:])
+	case "deploy":
+		deploy(client, args[1:])
+	case "register":
+		register(client, args[1:])
 	}
 }
 
-type Signer struct {
-	accman *accounts.Manager
-}
-
-func (sig Signer) Sign(signer types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
-	signature, err := sig.accman.Sign(address, signer.Hash(tx).Bytes())
-	if err != nil {
-		return nil, err
-	}
-
-	return tx.WithSignature(signer, signature)
-}
-
-func getAccount() (*accounts.Manager, accounts.Account, error) {
-	accman := accounts.NewManager(*uploadKeystoreFlag, 0, 0)
-	account, err := ethutils.MakeAddress(accman, *uploadAccountFlag)
-	if err != nil {
-		return nil, accounts.Account{}, fmt.Errorf("Error parsing account: %s", err)
-	}
-	log.Printf("Uploading using account %s", account.Address.Hex())
-
-	if err := accman.Unlock(account, *uploadPasswordFlag); err != nil {
-		return nil, accounts.Account{}, fmt.Errorf("Error unlocking account: %s", err)
-	}
-
-	return accman, account, nil
-}
-
 func readRRs(filename string) (rrs []dns.RR, soa *dns.SOA, err error) {
 	fh, err := os.Open(filename)
 	if err != nil {
@@ -149,7 +195,7 @@ func readRRs(filename string) (rrs []dns.RR, soa *dns.SOA, err error) {
 	return rrs, soa, nil
 }
 
-func upload(client *ethclient.Client, args []string) {
+func upload(client *failover.Client, args []string) {
 	uploadFlagSet.Parse(args)
 	args = uploadFlagSet.Args()
 
@@ -169,31 +215,42 @@ func upload(client *ethclient.Client, args []string) {
 		os.Exit(1)
 	}
 
-	parts := strings.Split(soa.Ns, ".")
-	if len(parts[0]) != 40 {
-		fmt.Println("SOA nameserver name must start with a 40 character hex address")
-	}
-
-	registryAddress := common.HexToAddress(parts[0])
-
-	if *uploadKeystoreFlag == "" {
-		fmt.Println("--keystore is required")
+	registryAddress, err := registryFromNS(soa.Ns)
+	if err != nil {
+		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	accman, account, err := getAccount()
+	// Dry runs and offline uploads only need the address, to estimate gas and
+	// choose nonces.
+	calldata := *uploadCalldataFlag || *uploadProposalOutFlag != ""
+	var txopts bind.TransactOpts
+	var backend bind.ContractBackend = client
+	var offline *offlineBackend
+	if *uploadDryRunFlag || *uploadUnsignedOutFlag != "" || calldata {
+		txopts.From, err = getAddress(uploadSignerFlags)
+		if *uploadUnsignedOutFlag != "" || calldata {
+			offline = &offlineBackend{ContractBackend: client}
+			backend, txopts.Signer = offline, offline.sign
+		}
+	} else {
+		var signer Signer
+		signer, err = getSigner(uploadSignerFlags, true)
+		if err == nil {
+			txopts.From, txopts.Signer = signer.Address(), signer.Sign
+		}
+	}
 	if err != nil {
 		fmt.Printf("Error getting account: %s\n", err)
 		os.Exit(1)
 	}
+	log.Printf("Uploading using account %s", txopts.From.Hex())
 
-	txopts := bind.TransactOpts{
-		From: account.Address,
-		Signer: (Signer{accman}).Sign,
-		GasLimit: big.NewInt(2000000),
+	if *uploadGasLimitFlag > 0 {
+		txopts.GasLimit = big.NewInt(*uploadGasLimitFlag)
 	}
 
-	registry, err := ens.New(client, registryAddress, txopts)
+	registry, err := ens.New(backend, registryAddress, txopts)
 	if err != nil {
 		fmt.Printf("Error constructing ENS instance: %v\n", err)
 		os.Exit(1)
@@ -202,12 +259,233 @@ func upload(client *ethclient.Client, args []string) {
 	resolver, err := registry.GetResolver(soa.Hdr.Name)
 	if err != nil {
 		fmt.Printf("Error getting resolver: %s\n", err)
+		os.Exit(1)
+	}
+	if resolver.Address == (common.Address{}) {
+		fmt.Printf("No resolver set for name %s\n", soa.Hdr.Name)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	if err := preflight(ctx, client, registry, resolver, txopts.From, soa.Hdr.Name); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	current, err := resolver.GetRRs()
+	if err != nil {
+		fmt.Printf("Error getting current RRs: %s\n", err)
+		os.Exit(1)
+	}
+
+	changes := diffRRSets(current, rrs)
+	if len(changes) == 0 {
+		fmt.Printf("No changes for name %s\n", soa.Hdr.Name)
+		return
+	}
+	printChanges(changes)
+
+	rrsets, err := resolver.SupportsRRSets(ctx)
+	if err != nil {
+		fmt.Printf("Error checking resolver interfaces: %s\n", err)
+		os.Exit(1)
+	}
+
+	if *uploadDryRunFlag {
+		planUpload(ctx, resolver, rrsets, rrs, changes)
+		return
+	}
+
+	// Offline uploads don't send anything, and the hashes of their
+	// transactions change once they're signed
+	report := func(n int, tx *types.Transaction, suffix string) {
+		if offline != nil {
+			fmt.Printf("Prepared transaction %d%s\n", n, suffix)
+		} else {
+			fmt.Printf("Sent transaction %s%s\n", tx.Hash().Hex(), suffix)
+		}
+	}
+
+	var txs []*types.Transaction
+	if !rrsets {
+		fmt.Printf("Setting %d RRs for name %s at resolver %s\n", len(rrs), soa.Hdr.Name, resolver.Address.Hex())
+		txs, err = resolver.SetRRsContext(ctx, rrs)
+		if err != nil {
+			fmt.Printf("Error setting RRs: %s\n", err)
+			os.Exit(1)
+		}
+		for i, tx := range txs {
+			report(i+1, tx, "")
+		}
+	} else {
+		fmt.Printf("Updating %d RRsets for name %s at resolver %s\n", len(changes), soa.Hdr.Name, resolver.Address.Hex())
+		for _, c := range changes {
+			tx, err := resolver.SetRRSetContext(ctx, c.key.name, c.key.rrtype, c.key.class, c.new)
+			if err != nil {
+				fmt.Printf("Error setting RRset %s: %s\n", c.key, err)
+				os.Exit(1)
+			}
+			txs = append(txs, tx)
+			report(len(txs), tx, " for RRset "+c.key.String())
+		}
+	}
+
+	if offline != nil {
+		writeOffline(offline, txopts.From)
+		return
+	}
+
+	if *uploadConfirmationsFlag <= 0 {
+		return
+	}
+	verifyUpload(ctx, client, resolver, txs, rrs, *uploadConfirmationsFlag)
+}
+
+// writeOffline outputs the transactions collected by an offline upload in the
+// forms requested by flags.
+func writeOffline(offline *offlineBackend, from common.Address) {
+	if *uploadUnsignedOutFlag != "" {
+		if err := offline.writeUnsigned(*uploadUnsignedOutFlag, from); err != nil {
+			fmt.Printf("Error writing unsigned transactions: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote %d unsigned transactions to %s\n", len(offline.txs), *uploadUnsignedOutFlag)
+	}
+
+	if *uploadCalldataFlag || *uploadProposalOutFlag != "" {
+		fmt.Printf("Send the following from %s:\n", from.Hex())
+		offline.printCalldata()
+	}
+	if *uploadProposalOutFlag != "" {
+		if err := offline.writeProposal(*uploadProposalOutFlag); err != nil {
+			fmt.Printf("Error writing proposal: %s\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote proposal to %s\n", *uploadProposalOutFlag)
+	}
+}
+
+// verifyUpload waits for txs to be confirmed, then checks the resolver now
+// returns rrs, exiting with an error if not.
+func verifyUpload(ctx context.Context, client *failover.Client, resolver *ens.Resolver, txs []*types.Transaction, rrs []dns.RR, confirmations int64) {
+	ok, err := waitForReceipts(ctx, client, txs, confirmations)
+	if err != nil {
+		fmt.Printf("Error waiting for transactions: %s\n", err)
+		os.Exit(1)
+	}
+	if !ok {
+		fmt.Println("Upload failed")
+		os.Exit(1)
+	}
+
+	stored, err := resolver.GetRRsContext(ctx)
+	if err != nil {
+		fmt.Printf("Error getting stored RRs: %s\n", err)
+		os.Exit(1)
+	}
+	if changes := diffRRSets(stored, rrs); len(changes) > 0 {
+		fmt.Println("Stored RRs differ from zonefile:")
+		printChanges(changes)
+		os.Exit(1)
+	}
+	fmt.Printf("Verified %d RRs stored at resolver %s\n", len(rrs), resolver.Address.Hex())
+}
+
+// preflight checks that account may change the records for name, so upload
+// doesn't waste gas on transactions that will fail.
+func preflight(ctx context.Context, client *failover.Client, registry *ens.Registry, resolver *ens.Resolver, account common.Address, name string) error {
+	owner, err := registry.GetOwnerContext(ctx, name)
+	if err != nil {
+		return fmt.Errorf("Error getting owner: %s", err)
+	}
+	if owner != account {
+		return fmt.Errorf("Account %s does not own name %s; it is owned by %s", account.Hex(), name, owner.Hex())
+	}
+
+	header, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("Error getting latest block: %s", err)
+	}
+	authorised, err := resolver.Authorised(ctx, header.GasLimit)
+	if err != nil {
+		return fmt.Errorf("Error checking resolver authorisation: %s", err)
+	}
+	if !authorised {
+		return fmt.Errorf("Resolver %s rejects changes to the records for name %s from account %s; the name is owned by %s", resolver.Address.Hex(), name, account.Hex(), owner.Hex())
+	}
+
+	fmt.Printf("Name %s is owned by %s and uses resolver %s\n", name, owner.Hex(), resolver.Address.Hex())
+	return nil
+}
+
+// planUpload prints the transactions upload would send to make the changes, and
+// the gas they're estimated to use.
+func planUpload(ctx context.Context, resolver *ens.Resolver, rrsets bool, rrs []dns.RR, changes []rrsetChange) {
+	rdata, err := ens.PackRRs(rrs)
+	if err != nil {
+		fmt.Printf("Error packing RRs: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Zone has %d RRs in %d RRsets, %d bytes packed\n", len(rrs), len(groupRRSets(rrs)), len(rdata))
+
+	if !rrsets {
+		chunks, claims, err := resolver.CountSetRRs(ctx, rrs)
+		if err != nil {
+			fmt.Printf("Error counting transactions: %s\n", err)
+			os.Exit(1)
+		}
+		gas, err := resolver.EstimateSetRRs(ctx, rrs)
+		if err != nil {
+			fmt.Printf("Error estimating gas: %s\n", err)
+			os.Exit(1)
+		}
+		if claims > 0 {
+			fmt.Printf("Would first claim %d subnodes of the zone to store chunks in\n", claims)
+		}
+		fmt.Printf("Would set %d RRs in %d chunks to resolver %s, sending %d transactions (estimated gas %v)\n", len(rrs), chunks, resolver.Address.Hex(), chunks+claims, gas)
+		return
 	}
 
-	fmt.Printf("Setting %d RRs for name %s at resolver %s\n", len(rrs), soa.Hdr.Name, resolver.Address.Hex())
-	if err := resolver.SetRRs(rrs); err != nil {
-		fmt.Printf("Error setting RRs: %s\n", err)
+	total := new(big.Int)
+	for _, c := range changes {
+		gas, err := resolver.EstimateSetRRSet(ctx, c.key.name, c.key.rrtype, c.key.class, c.new)
+		if err != nil {
+			fmt.Printf("Error estimating gas for RRset %s: %s\n", c.key, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Would set RRset %s (estimated gas %v)\n", c.key, gas)
+		total.Add(total, gas)
+	}
+	fmt.Printf("Would send %d transactions to resolver %s (estimated gas %v)\n", len(changes), resolver.Address.Hex(), total)
+}
+
+// registryFromNS returns the address of the registry an ENS nameserver name
+// refers to, which is given in hex by its first label.
+func registryFromNS(ns string) (common.Address, error) {
+	parts := strings.Split(ns, ".")
+	if len(parts[0]) != 40 || !common.IsHexAddress(parts[0]) {
+		return common.Address{}, fmt.Errorf("SOA nameserver name '%s' does not start with a 40 character hex address", ns)
 	}
+	return common.HexToAddress(parts[0]), nil
+}
+
+// findRegistry follows the delegation of name from the root servers, as the
+// server does, and returns the registry it's delegated to and the zone's apex.
+func findRegistry(name string) (common.Address, string, error) {
+	client := &dns.Client{
+		ReadTimeout: 5 * time.Second,
+	}
+
+	ns, err := utils.FindNS(client, rootServers, name, *nsDomainFlag)
+	if err != nil {
+		return common.Address{}, "", err
+	}
+
+	registryAddress, err := registryFromNS(ns.Ns)
+	if err != nil {
+		return common.Address{}, "", err
+	}
+	return registryAddress, ns.Hdr.Name, nil
 }
 
 type nsCacheKey string
@@ -216,84 +494,277 @@ type nsCacheEntry struct {
 	expires time.Time
 	registry common.Address
 	root string
+	ns *dns.NS // The delegation to the registry
+	err error
 }
 
+// zoneCacheKey identifies a zone by its registry and apex, so one cached zone
+// serves every name within it.
 type zoneCacheKey struct {
 	registryAddress common.Address
-	name string
+	root string
+}
+
+func (key zoneCacheKey) String() string {
+	return key.registryAddress.Hex() + "/" + key.root
 }
 
 type zoneCacheEntry struct {
 	expires time.Time
+	prefetch time.Time
 	value *Zone
+	err error // Set instead of value for zones that can't be served
+}
+
+// zoneBackend is what the server needs from an Ethereum client to fetch zones.
+// It's satisfied by failover.Client.
+type zoneBackend interface {
+	bind.ContractBackend
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
 }
 
 type ENSDNS struct {
-	client *ethclient.Client
+	client zoneBackend
 	cache *lru.ARCCache
+	prefetch float64
+	confirmations int64
+	queryTimeout time.Duration
+	negativeTTL time.Duration
+	store *zoneStore
+
+	nsGroup singleflight.Group
+	zoneGroup singleflight.Group
+
+	prefetchLock sync.Mutex
+	prefetching map[zoneCacheKey]bool
 }
 
 func (ed *ENSDNS) getRegistryAddress(name string) (common.Address, string, error) {
 	// First, check the cache
 	if entry, ok := ed.cache.Get(nsCacheKey(name)); ok && time.Now().Before(entry.(nsCacheEntry).expires) {
-		return entry.(nsCacheEntry).registry, entry.(nsCacheEntry).root, nil
+		return entry.(nsCacheEntry).registry, entry.(nsCacheEntry).root, entry.(nsCacheEntry).err
+	}
+
+	// Only one lookup per name is in flight at a time; concurrent callers share its result.
+	entry, err, _ := ed.nsGroup.Do(name, func() (interface{}, error) {
+		return ed.findRegistryAddress(name)
+	})
+	if err != nil {
+		return common.Address{}, "", err
 	}
+	return entry.(nsCacheEntry).registry, entry.(nsCacheEntry).root, nil
+}
 
+func (ed *ENSDNS) findRegistryAddress(name string) (nsCacheEntry, error) {
 	client := &dns.Client{
 		ReadTimeout: 5 * time.Second,
 	}
 
 	ns, err := utils.FindNS(client, rootServers, name, *nsDomainFlag)
+	if err, ok := err.(*utils.NotDelegatedError); ok {
+		// Cache negative results too, so queries for unrelated names don't walk the root servers each time
+		entry := nsCacheEntry{expires: time.Now().Add(time.Duration(err.Ttl) * time.Second), err: err}
+		ed.cache.Add(nsCacheKey(name), entry)
+		return entry, err
+	}
 	if err != nil {
-		return common.Address{}, "", err
+		return nsCacheEntry{}, err
 	}
 
-	parts := strings.Split(ns.Ns, ".")
-	if len(parts[0]) != 40 {
-		return common.Address{}, "", fmt.Errorf("SOA nameserver name '%s' does not start with a 40 character hex address", ns.Ns)
+	registryAddress, err := registryFromNS(ns.Ns)
+	if err != nil {
+		return nsCacheEntry{}, err
 	}
 
-	registryAddress := common.HexToAddress(parts[0])	
-	ed.cache.Add(nsCacheKey(name), nsCacheEntry{time.Now().Add(time.Duration(ns.Hdr.Ttl) * time.Second), registryAddress, ns.Hdr.Name})
-	return registryAddress, ns.Hdr.Name, nil
+	entry := nsCacheEntry{time.Now().Add(time.Duration(ns.Hdr.Ttl) * time.Second), registryAddress, ns.Hdr.Name, ns, nil}
+	ed.cache.Add(nsCacheKey(name), entry)
+	return entry, nil
 }
 
-func (ed *ENSDNS) getZone(name string) (*Zone, error) {
+func (ed *ENSDNS) getZone(ctx context.Context, name string) (*Zone, error) {
 	registryAddress, root, err := ed.getRegistryAddress(name)
 	if err != nil {
 		return nil, err
 	}
 
 	// First, check the cache
-	cacheKey := zoneCacheKey{registryAddress, name}
-	if entry, ok := ed.cache.Get(cacheKey); ok && time.Now().Before(entry.(zoneCacheEntry).expires) {
-		return entry.(zoneCacheEntry).value, nil
+	cacheKey := zoneCacheKey{registryAddress, strings.ToLower(root)}
+	if entry, ok := ed.cache.Get(cacheKey); ok {
+		entry := entry.(zoneCacheEntry)
+		now := time.Now()
+		if now.Before(entry.expires) {
+			if entry.err != nil {
+				return nil, entry.err
+			}
+			// Refresh zones nearing expiry in the background, so popular zones
+			// never have to be fetched inline.
+			if !now.Before(entry.prefetch) {
+				go ed.prefetchZone(cacheKey)
+			}
+			return entry.value, nil
+		}
+	}
+
+	return ed.loadZone(ctx, cacheKey)
+}
+
+// loadZone fetches a zone, sharing the result with any concurrent fetches of the same zone.
+// The shared fetch is bounded by the query timeout rather than any one caller's ctx, so a
+// caller giving up early doesn't fail the others; each stops waiting when its own ctx is done.
+func (ed *ENSDNS) loadZone(ctx context.Context, cacheKey zoneCacheKey) (*Zone, error) {
+	ch := ed.zoneGroup.DoChan(cacheKey.String(), func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), ed.queryTimeout)
+		defer cancel()
+		return ed.fetchZone(ctx, cacheKey)
+	})
+	select {
+	case result := <-ch:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.(*Zone), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
+}
 
-	registry, err := ens.New(ed.client, registryAddress, bind.TransactOpts{})
+func (ed *ENSDNS) fetchZone(ctx context.Context, cacheKey zoneCacheKey) (*Zone, error) {
+	head, err := ed.client.HeaderByNumber(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("Error constructing ENS instance: %v", err)
+		return nil, fmt.Errorf("Error getting latest block: %v", err)
 	}
 
-	resolver, err := registry.GetResolver(root)
+	// Read at a confirmed block, so a reorg can't briefly serve records that later disappear
+	block := new(big.Int).Sub(head.Number, big.NewInt(ed.confirmations))
+	if block.Sign() < 0 {
+		block.SetInt64(0)
+	}
+
+	registry, err := ens.New(ens.AtBlock(ed.client, block), cacheKey.registryAddress, bind.TransactOpts{})
 	if err != nil {
-		return nil, fmt.Errorf("Error getting resolver: %s", err)
+		return nil, fmt.Errorf("Error constructing ENS instance: %v", err)
+	}
+
+	// If we've seen this zone before, its resolver probably hasn't changed, which
+	// lets the lookup fetch everything in one round trip.
+	var hint common.Address
+	if entry, ok := ed.cache.Peek(cacheKey); ok && entry.(zoneCacheEntry).value != nil {
+		hint = entry.(zoneCacheEntry).value.resolver
 	}
 
-	rrs, err := resolver.GetRRs()
+	record, err := registry.Lookup(ctx, cacheKey.root, hint)
+	if err == ens.NoResolverError {
+		return nil, ed.cacheFailure(cacheKey, fmt.Errorf("Error getting records: %s", err))
+	}
 	if err != nil {
-		return nil, fmt.Errorf("Error getting records from resolver: %s", err)
+		return nil, fmt.Errorf("Error getting records: %s", err)
 	}
+	rrs := record.RRs
 
 	zone := NewZone(rrs)
-	ed.cache.Add(cacheKey, zoneCacheEntry{time.Now().Add(time.Duration(zone.soa.Refresh) * time.Second), zone})
+	if zone.soa == nil {
+		return nil, ed.cacheFailure(cacheKey, fmt.Errorf("Zone %s has no SOA record", cacheKey.root))
+	}
+	zone.block = block.Uint64()
+	zone.resolver = record.Resolver.Address
+	expires := time.Now().Add(time.Duration(zone.soa.Refresh) * time.Second)
+	ed.cacheZone(cacheKey, zone, expires)
+
+	if ed.store != nil {
+		data, err := ens.PackRRs(rrs)
+		if err == nil {
+			err = ed.store.Put(storedZone{
+				Registry: cacheKey.registryAddress,
+				Root: cacheKey.root,
+				Resolver: zone.resolver,
+				Block: zone.block,
+				Expires: expires,
+				Data: data,
+			})
+		}
+		if err != nil {
+			log.Printf("Error storing zone %v: %v", cacheKey.root, err)
+		}
+	}
 	return zone, nil
 }
 
+func (ed *ENSDNS) cacheZone(cacheKey zoneCacheKey, zone *Zone, expires time.Time) {
+	lifetime := time.Duration(zone.soa.Refresh) * time.Second
+	prefetch := expires.Add(-time.Duration(float64(lifetime) * ed.prefetch))
+	// Adding the new entry replaces any existing one in a single step, so
+	// concurrent queries see either the old zone or the new one.
+	ed.cache.Add(cacheKey, zoneCacheEntry{expires, prefetch, zone, nil})
+}
+
+// cacheFailure caches err as the result of fetching a zone that can't be served,
+// so it isn't fetched again for every query until the negative TTL passes. It
+// returns err.
+func (ed *ENSDNS) cacheFailure(cacheKey zoneCacheKey, err error) error {
+	expires := time.Now().Add(ed.negativeTTL)
+	ed.cache.Add(cacheKey, zoneCacheEntry{expires: expires, prefetch: expires, err: err})
+	return err
+}
+
+// loadStore populates the cache with the unexpired zones saved in the zone store.
+func (ed *ENSDNS) loadStore() error {
+	count := 0
+	err := ed.store.ForEach(func(stored storedZone) {
+		rrs, err := ens.UnpackRRs(stored.Data)
+		if err != nil {
+			log.Printf("Error unpacking stored zone %v: %v", stored.Root, err)
+			return
+		}
+		zone := NewZone(rrs)
+		if zone.soa == nil {
+			return
+		}
+		zone.block = stored.Block
+		zone.resolver = stored.Resolver
+		ed.cacheZone(zoneCacheKey{stored.Registry, stored.Root}, zone, stored.Expires)
+		count++
+	})
+	log.Printf("Loaded %d zones from the zone store", count)
+	return err
+}
+
+// pruneStore deletes zones from the zone store once they expire or are evicted
+// from the cache, so it doesn't grow without bound.
+func (ed *ENSDNS) pruneStore() error {
+	return ed.store.Prune(func(stored storedZone) bool {
+		return ed.cache.Contains(zoneCacheKey{stored.Registry, stored.Root})
+	})
+}
+
+func (ed *ENSDNS) prefetchZone(cacheKey zoneCacheKey) {
+	ed.prefetchLock.Lock()
+	if ed.prefetching[cacheKey] {
+		ed.prefetchLock.Unlock()
+		return
+	}
+	ed.prefetching[cacheKey] = true
+	ed.prefetchLock.Unlock()
+
+	defer func() {
+		ed.prefetchLock.Lock()
+		delete(ed.prefetching, cacheKey)
+		ed.prefetchLock.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), ed.queryTimeout)
+	defer cancel()
+	if _, err := ed.loadZone(ctx, cacheKey); err != nil {
+		log.Printf("Error prefetching zone %v: %v", cacheKey.root, err)
+	}
+}
+
 type Zone struct {
 	rrs []dns.RR
 	soa *dns.SOA
 	subdomains map[string]*Zone
+	// Only set on the apex
+	block uint64 // Block number the zone was read at
+	resolver common.Address
 }
 
 func NewZone(rrs []dns.RR) *Zone {
@@ -329,6 +800,15 @@ func NewZone(rrs []dns.RR) *Zone {
 	return root
 }
 
+// recordCount returns the number of records in the zone and its subdomains.
+func (z *Zone) recordCount() int {
+	count := len(z.rrs)
+	for _, sz := range z.subdomains {
+		count += sz.recordCount()
+	}
+	return count
+}
+
 func (z *Zone) findSubzone(question dns.Question) (rrs []dns.RR) {
 	labels := strings.Split(strings.ToLower(question.Name), ".")
 	zone := z
@@ -352,6 +832,8 @@ func (z *Zone) findSubzone(question dns.Question) (rrs []dns.RR) {
 func (z *Zone) Resolve(question dns.Question) (rrs []dns.RR, err error) {
 	for _, rr := range z.findSubzone(question) {
 		if question.Qtype == rr.Header().Rrtype || question.Qtype == dns.TypeANY {
+			// Zones are shared between queries, so rename a copy rather than the original
+			rr = dns.Copy(rr)
 			rr.Header().Name = question.Name
 			rrs = append(rrs, rr)
 		}
@@ -371,10 +853,17 @@ func (ed *ENSDNS) Handle(w dns.ResponseWriter, r *dns.Msg) {
 	m := new(dns.Msg)
 	m.SetReply(r)
 
+	// Don't let a slow Ethereum node hold up the query indefinitely
+	ctx, cancel := context.WithTimeout(context.Background(), ed.queryTimeout)
+	defer cancel()
+
 	for _, question := range r.Question {
-		zone, err := ed.getZone(question.Name)
+		zone, err := ed.getZone(ctx, question.Name)
 		if err != nil {
 			log.Printf("Zone %v not found: %v", question.Name, err)
+			if _, ok := err.(*utils.NotDelegatedError); ok {
+				m.Rcode = dns.RcodeRefused
+			}
 			break
 		}
 
@@ -399,7 +888,7 @@ func runServer(addr, proto string) {
 	}
 }
 
-func serve(client *ethclient.Client, args []string) {
+func serve(client *failover.Client, args []string) {
 	serveFlagSet.Parse(args)
 	args = serveFlagSet.Args()
 
@@ -408,12 +897,24 @@ func serve(client *ethclient.Client, args []string) {
 		os.Exit(1)
 	}
 
+	if *confirmationsFlag < 0 {
+		fmt.Println("-confirmations must not be negative")
+		os.Exit(1)
+	}
+
+	if *prefetchFlag < 0 || *prefetchFlag >= 1 {
+		fmt.Println("-prefetch must be at least 0 and less than 1")
+		os.Exit(1)
+	}
+
 	arc, err := lru.NewARC(*cacheSizeFlag)
 	if err != nil {
 		fmt.Printf("Error creating ARC cache: %s", err)
 		os.Exit(1)
 	}
 
+	client.CheckHealth(*healthIntervalFlag)
+
 	// Randomly shuffle the root server list on startup
 	for i, j := range rand.Perm(len(rootServers)) {
 		rootServers[i], rootServers[j] = rootServers[j], rootServers[i]
@@ -422,6 +923,24 @@ func serve(client *ethclient.Client, args []string) {
 	ensdns := &ENSDNS{
 		client: client,
 		cache: arc,
+		prefetch: *prefetchFlag,
+		confirmations: *confirmationsFlag,
+		queryTimeout: *queryTimeoutFlag,
+		negativeTTL: *negativeTTLFlag,
+		prefetching: make(map[zoneCacheKey]bool),
+	}
+
+	if *cacheDirFlag != "" {
+		store, err := openZoneStore(*cacheDirFlag)
+		if err != nil {
+			fmt.Printf("Error opening zone store: %s\n", err)
+			os.Exit(1)
+		}
+		ensdns.store = store
+		if err := ensdns.loadStore(); err != nil {
+			fmt.Printf("Error loading zone store: %s\n", err)
+			os.Exit(1)
+		}
 	}
 	dns.HandleFunc(".", ensdns.Handle)
 
@@ -430,5 +949,28 @@ func serve(client *ethclient.Client, args []string) {
 
 	log.Printf("Listening on %s", *listenAddressFlag)
 
-	select {}
+	var prune <-chan time.Time
+	if ensdns.store != nil {
+		ticker := time.NewTicker(*cachePruneFlag)
+		defer ticker.Stop()
+		prune = ticker.C
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	for {
+		select {
+		case <-prune:
+			if err := ensdns.pruneStore(); err != nil {
+				log.Printf("Error pruning zone store: %v", err)
+			}
+		case sig := <-signals:
+			log.Printf("Received %v, shutting down", sig)
+			if ensdns.store != nil {
+				if err := ensdns.store.Close(); err != nil {
+					log.Printf("Error closing zone store: %v", err)
+				}
+			}
+			return
+		}
+	}
 }
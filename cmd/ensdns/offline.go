@@ -0,0 +1,230 @@
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/arachnid/ensdns/failover"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"golang.org/x/net/context"
+)
+
+// unsignedTx describes a transaction to be signed elsewhere, in the form taken
+// by eth_signTransaction and external signers.
+type unsignedTx struct {
+	From     common.Address  `json:"from"`
+	To       *common.Address `json:"to"`
+	Gas      hexutil.Uint64  `json:"gas"`
+	GasPrice *hexutil.Big    `json:"gasPrice"`
+	Value    *hexutil.Big    `json:"value"`
+	Nonce    hexutil.Uint64  `json:"nonce"`
+	Data     hexutil.Bytes   `json:"data"`
+}
+
+func newUnsignedTx(from common.Address, tx *types.Transaction) unsignedTx {
+	return unsignedTx{
+		From:     from,
+		To:       tx.To(),
+		Gas:      hexutil.Uint64(tx.Gas().Uint64()),
+		GasPrice: (*hexutil.Big)(tx.GasPrice()),
+		Value:    (*hexutil.Big)(tx.Value()),
+		Nonce:    hexutil.Uint64(tx.Nonce()),
+		Data:     hexutil.Bytes(tx.Data()),
+	}
+}
+
+// offlineBackend collects the transactions a contract binding sends instead of
+// sending them, so they can be signed or sent elsewhere. Nonces are assigned as
+// if each collected transaction had been sent.
+//
+// It deliberately doesn't implement bind.DeployBackend, since collected
+// transactions will never be mined.
+type offlineBackend struct {
+	bind.ContractBackend
+	txs []*types.Transaction
+}
+
+func (b *offlineBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	nonce, err := b.ContractBackend.PendingNonceAt(ctx, account)
+	return nonce + uint64(len(b.txs)), err
+}
+
+func (b *offlineBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	b.txs = append(b.txs, tx)
+	return nil
+}
+
+// sign is a bind.SignerFn that leaves transactions unsigned.
+func (b *offlineBackend) sign(signer types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	return tx, nil
+}
+
+// writeUnsigned writes the collected transactions, from account from, to a
+// JSON file at path.
+func (b *offlineBackend) writeUnsigned(path string, from common.Address) error {
+	txs := make([]unsignedTx, len(b.txs))
+	for i, tx := range b.txs {
+		txs[i] = newUnsignedTx(from, tx)
+	}
+	data, err := json.MarshalIndent(txs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// proposedCall describes a call for an owning contract, such as a multisig
+// wallet, to make.
+type proposedCall struct {
+	To    common.Address `json:"to"`
+	Value string         `json:"value"` // In wei, as a decimal string
+	Data  hexutil.Bytes  `json:"data"`
+}
+
+// printCalldata prints the target and calldata of each collected transaction.
+func (b *offlineBackend) printCalldata() {
+	for i, tx := range b.txs {
+		fmt.Printf("Transaction %d:\n", i+1)
+		fmt.Printf("  to:    %s\n", tx.To().Hex())
+		fmt.Printf("  value: %v\n", tx.Value())
+		fmt.Printf("  data:  %s\n", hexutil.Encode(tx.Data()))
+	}
+}
+
+// writeProposal writes the calls the collected transactions make to a JSON
+// file at path, for import into a multisig wallet's interface.
+func (b *offlineBackend) writeProposal(path string) error {
+	calls := make([]proposedCall, len(b.txs))
+	for i, tx := range b.txs {
+		calls[i] = proposedCall{*tx.To(), tx.Value().String(), tx.Data()}
+	}
+	data, err := json.MarshalIndent(calls, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// readSigned reads signed transactions from path. The file may hold hex-encoded
+// RLP transactions, one per line, or the JSON results of signing the entries
+// written by writeUnsigned with eth_signTransaction or an external signer such
+// as clef: either a single result, or an array of them in the order they were
+// written. Each result is an object whose "raw" field holds the signed
+// transaction; other fields, such as "tx", are ignored.
+func readSigned(path string) ([]*types.Transaction, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening file: %s", err)
+	}
+
+	var raws []hexutil.Bytes
+	switch trimmed := bytes.TrimSpace(data); {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		var results []signedResult
+		if err := json.Unmarshal(trimmed, &results); err != nil {
+			return nil, fmt.Errorf("Error decoding JSON: %s", err)
+		}
+		for _, result := range results {
+			raws = append(raws, result.Raw)
+		}
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		var result signedResult
+		if err := json.Unmarshal(trimmed, &result); err != nil {
+			return nil, fmt.Errorf("Error decoding JSON: %s", err)
+		}
+		raws = append(raws, result.Raw)
+	default:
+		for _, line := range strings.Split(string(trimmed), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			raw, err := hexutil.Decode(line)
+			if err != nil {
+				return nil, fmt.Errorf("Error decoding transaction %d: %s", len(raws)+1, err)
+			}
+			raws = append(raws, raw)
+		}
+	}
+
+	txs := make([]*types.Transaction, len(raws))
+	for i, raw := range raws {
+		if len(raw) == 0 {
+			return nil, fmt.Errorf("Error decoding transaction %d: no raw transaction", i+1)
+		}
+		txs[i] = new(types.Transaction)
+		if err := rlp.DecodeBytes(raw, txs[i]); err != nil {
+			return nil, fmt.Errorf("Error decoding transaction %d: %s", i+1, err)
+		}
+	}
+	return txs, nil
+}
+
+// signedResult is the result of eth_signTransaction or an external signer's
+// account_signTransaction.
+type signedResult struct {
+	Raw hexutil.Bytes `json:"raw"`
+}
+
+func broadcast(client *failover.Client, args []string) {
+	broadcastFlagSet.Parse(args)
+	args = broadcastFlagSet.Args()
+
+	if len(args) != 1 {
+		fmt.Println("usage: ensdns broadcast [flags] <filename>")
+		fmt.Println("The file holds hex-encoded signed transactions, one per line, or the JSON")
+		fmt.Println("results of signing the transactions written by upload -unsigned-out with")
+		fmt.Println("eth_signTransaction or an external signer such as clef.")
+		os.Exit(1)
+	}
+
+	txs, err := readSigned(args[0])
+	if err != nil {
+		fmt.Printf("Error reading signed transactions: %s\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	for _, tx := range txs {
+		if err := client.SendTransaction(ctx, tx); err != nil {
+			fmt.Printf("Error sending transaction %s: %s\n", tx.Hash().Hex(), err)
+			os.Exit(1)
+		}
+		fmt.Printf("Sent transaction %s\n", tx.Hash().Hex())
+	}
+
+	if *broadcastConfirmationsFlag <= 0 {
+		return
+	}
+	ok, err := waitForReceipts(ctx, client, txs, *broadcastConfirmationsFlag)
+	if err != nil {
+		fmt.Printf("Error waiting for transactions: %s\n", err)
+		os.Exit(1)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// TestSignOffline writes unsigned transactions as upload -unsigned-out does,
+// signs them as an external signer would, and reads them back as broadcast
+// does, in each of the forms it accepts.
+func TestSignOffline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ensdns-offline")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, _ := crypto.GenerateKey()
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	to := common.HexToAddress("0x1234")
+	backend := &offlineBackend{txs: []*types.Transaction{
+		types.NewTransaction(0, to, big.NewInt(0), big.NewInt(100000), big.NewInt(20), []byte{1, 2, 3}),
+		types.NewTransaction(1, to, big.NewInt(0), big.NewInt(200000), big.NewInt(20), []byte{4, 5, 6}),
+	}}
+	unsignedPath := filepath.Join(dir, "tx.json")
+	if err := backend.writeUnsigned(unsignedPath, from); err != nil {
+		t.Fatalf("Error writing unsigned transactions: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(unsignedPath)
+	if err != nil {
+		t.Fatalf("Error reading unsigned transactions: %s", err)
+	}
+	var unsigned []json.RawMessage
+	if err := json.Unmarshal(data, &unsigned); err != nil {
+		t.Fatalf("Error decoding unsigned transactions: %s", err)
+	}
+
+	// Sign each transaction, keeping the results in the form clef returns them
+	type clefResult struct {
+		Raw hexutil.Bytes      `json:"raw"`
+		Tx  *types.Transaction `json:"tx"`
+	}
+	signer := &FakeSigner{key: key}
+	var results []clefResult
+	var lines []string
+	for i, args := range unsigned {
+		result, err := signer.SignTransaction(args)
+		if err != nil {
+			t.Fatalf("Error signing transaction %d: %s", i, err)
+		}
+		tx := new(types.Transaction)
+		if err := rlp.DecodeBytes(result["raw"], tx); err != nil {
+			t.Fatalf("Error decoding signed transaction %d: %s", i, err)
+		}
+		results = append(results, clefResult{result["raw"], tx})
+		lines = append(lines, hexutil.Encode(result["raw"]))
+	}
+
+	array, _ := json.MarshalIndent(results, "", "  ")
+	single, _ := json.Marshal(results[0])
+	for _, test := range []struct {
+		what  string
+		data  string
+		count int
+	}{
+		{"hex lines", strings.Join(lines, "\n") + "\n", 2},
+		{"JSON array", string(array), 2},
+		{"single JSON result", string(single), 1},
+	} {
+		signedPath := filepath.Join(dir, "tx.signed")
+		if err := ioutil.WriteFile(signedPath, []byte(test.data), 0644); err != nil {
+			t.Fatalf("Error writing signed transactions: %s", err)
+		}
+		txs, err := readSigned(signedPath)
+		if err != nil {
+			t.Errorf("%s: Error reading signed transactions: %s", test.what, err)
+			continue
+		}
+		if len(txs) != test.count {
+			t.Errorf("%s: Got %d transactions, want %d", test.what, len(txs), test.count)
+			continue
+		}
+		for i, tx := range txs {
+			sender, err := types.Sender(types.HomesteadSigner{}, tx)
+			if err != nil || sender != from {
+				t.Errorf("%s: Transaction %d is from %s (%v), want %s", test.what, i, sender.Hex(), err, from.Hex())
+			}
+			if !sameTransaction(tx, backend.txs[i]) {
+				t.Errorf("%s: Got transaction %d %v, want %v", test.what, i, tx, backend.txs[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/arachnid/ensdns/failover"
+	"github.com/arachnid/ensdns/utils"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// query answers a single question using the same steps as the server, printing
+// the result of each, to help diagnose why a name doesn't resolve.
+func query(client *failover.Client, args []string) {
+	queryFlagSet.Parse(args)
+	args = queryFlagSet.Args()
+
+	if len(args) < 1 || len(args) > 2 {
+		fmt.Println("usage: ensdns query [flags] <name> [type]")
+		os.Exit(1)
+	}
+
+	question := dns.Question{Name: dns.Fqdn(args[0]), Qtype: dns.TypeA, Qclass: dns.ClassINET}
+	if len(args) == 2 {
+		qtype, ok := dns.StringToType[strings.ToUpper(args[1])]
+		if !ok {
+			fmt.Printf("Unknown record type %s\n", args[1])
+			os.Exit(1)
+		}
+		question.Qtype = qtype
+	}
+
+	if *queryConfirmationsFlag < 0 {
+		fmt.Println("-confirmations must not be negative")
+		os.Exit(1)
+	}
+
+	arc, err := lru.NewARC(16)
+	if err != nil {
+		fmt.Printf("Error creating ARC cache: %s\n", err)
+		os.Exit(1)
+	}
+	ed := &ENSDNS{
+		client:        client,
+		cache:         arc,
+		confirmations: *queryConfirmationsFlag,
+		queryTimeout:  *queryDeadlineFlag,
+		prefetching:   make(map[zoneCacheKey]bool),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *queryDeadlineFlag)
+	defer cancel()
+
+	fmt.Printf("Question: %s\n", strings.TrimPrefix(question.String(), ";"))
+
+	delegation, err := ed.findRegistryAddress(question.Name)
+	if err, ok := err.(*utils.NotDelegatedError); ok {
+		fmt.Printf("%s; the server would refuse the query for %ds\n", err, err.Ttl)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Printf("Error finding delegation: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Delegation: %s\n", delegation.ns.String())
+	fmt.Printf("Zone apex: %s\n", delegation.root)
+	fmt.Printf("Registry: %s\n", delegation.registry.Hex())
+
+	zone, err := ed.getZone(ctx, question.Name)
+	if err != nil {
+		fmt.Printf("Error fetching zone: %s (server would fail)\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Resolver: %s\n", zone.resolver.Hex())
+	fmt.Printf("Read at block: %d\n", zone.block)
+	fmt.Printf("Records in zone: %d\n", zone.recordCount())
+
+	rrs, err := zone.Resolve(question)
+	if err != nil {
+		fmt.Printf("Error resolving query: %s (server would fail)\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Answer: %d records\n", len(rrs))
+	for _, rr := range rrs {
+		fmt.Println(rr.String())
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/arachnid/ensdns/failover"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"golang.org/x/net/context"
+)
+
+// waitForReceipts waits for each of txs to be mined, printing the outcome of
+// each, and then for the last to be confirmed by confirmations blocks, counting
+// the one that included it. It returns false if any transaction failed.
+//
+// Receipts don't record whether a transaction succeeded, so a transaction that
+// used all the gas it was given is assumed to have thrown.
+func waitForReceipts(ctx context.Context, client *failover.Client, txs []*types.Transaction, confirmations int64) (bool, error) {
+	ok := true
+	var minedBy uint64
+	for _, tx := range txs {
+		fmt.Printf("Waiting for transaction %s to be mined\n", tx.Hash().Hex())
+		receipt, err := bind.WaitMined(ctx, client, tx)
+		if err != nil {
+			return false, err
+		}
+
+		// Nor do they say which block included them, so count confirmations
+		// from the latest block once the receipt is seen.
+		header, err := client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return false, err
+		}
+		minedBy = header.Number.Uint64()
+
+		if receipt.GasUsed.Cmp(tx.Gas()) >= 0 {
+			fmt.Printf("Transaction %s failed: used all %v gas\n", tx.Hash().Hex(), receipt.GasUsed)
+			ok = false
+		} else {
+			fmt.Printf("Transaction %s succeeded: used %v gas\n", tx.Hash().Hex(), receipt.GasUsed)
+		}
+	}
+
+	if confirmations <= 1 || len(txs) == 0 {
+		return ok, nil
+	}
+	target := minedBy + uint64(confirmations) - 1
+	fmt.Printf("Waiting for block %d for %d confirmations\n", target, confirmations)
+	for {
+		header, err := client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return false, err
+		}
+		if header.Number.Uint64() >= target {
+			return ok, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strconv"
+
+	"github.com/arachnid/ensdns/ens"
+	"github.com/arachnid/ensdns/failover"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/miekg/dns"
+	"golang.org/x/net/context"
+)
+
+// txFlags are the flags shared by commands that send transactions.
+type txFlags struct {
+	signer        *signerFlags
+	gasLimit      *int64
+	confirmations *int64
+}
+
+func newTxFlags(fs *flag.FlagSet) *txFlags {
+	return &txFlags{
+		signer:        newSignerFlags(fs),
+		gasLimit:      fs.Int64("gaslimit", 0, "Gas limit for each transaction (0 to estimate)"),
+		confirmations: fs.Int64("confirmations", 1, "Number of blocks to wait for, including the one that mines the transaction (0 to not wait)"),
+	}
+}
+
+// registryFlags are the flags shared by commands that change a name in the
+// registry.
+type registryFlags struct {
+	*txFlags
+	registry *string
+}
+
+func newRegistryFlags(fs *flag.FlagSet) *registryFlags {
+	return &registryFlags{
+		txFlags:  newTxFlags(fs),
+		registry: fs.String("registry", "", "Address of the ENS registry (default: found by following the name's delegation)"),
+	}
+}
+
+// transactOpts returns options for sending transactions signed as selected by
+// the flags.
+func (tf *txFlags) transactOpts() (bind.TransactOpts, error) {
+	signer, err := getSigner(tf.signer, true)
+	if err != nil {
+		return bind.TransactOpts{}, err
+	}
+	log.Printf("Sending transactions from account %s", signer.Address().Hex())
+
+	opts := bind.TransactOpts{
+		From:   signer.Address(),
+		Signer: signer.Sign,
+	}
+	if *tf.gasLimit > 0 {
+		opts.GasLimit = big.NewInt(*tf.gasLimit)
+	}
+	return opts, nil
+}
+
+// registryAddress returns the registry selected by the flags, or else the one
+// name is delegated to.
+func (rf *registryFlags) registryAddress(name string) (common.Address, error) {
+	if *rf.registry != "" {
+		if !common.IsHexAddress(*rf.registry) {
+			return common.Address{}, fmt.Errorf("-registry must be a hex address")
+		}
+		return common.HexToAddress(*rf.registry), nil
+	}
+	addr, _, err := findRegistry(name)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("Error finding registry for %s: %s", name, err)
+	}
+	return addr, nil
+}
+
+// openRegistry returns the registry for name, set up to send transactions from
+// the account selected by the flags, exiting with an error if it can't.
+func (rf *registryFlags) openRegistry(client *failover.Client, name string) (*ens.Registry, bind.TransactOpts) {
+	registryAddress, err := rf.registryAddress(name)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	opts, err := rf.transactOpts()
+	if err != nil {
+		fmt.Printf("Error getting account: %s\n", err)
+		os.Exit(1)
+	}
+
+	registry, err := ens.New(client, registryAddress, opts)
+	if err != nil {
+		fmt.Printf("Error constructing ENS instance: %v\n", err)
+		os.Exit(1)
+	}
+	return registry, opts
+}
+
+// openOwnedRegistry is like openRegistry, but also checks that the account owns
+// name.
+func (rf *registryFlags) openOwnedRegistry(client *failover.Client, name string) *ens.Registry {
+	registry, opts := rf.openRegistry(client, name)
+	owner, err := registry.GetOwner(name)
+	if err != nil {
+		fmt.Printf("Error getting owner: %s\n", err)
+		os.Exit(1)
+	}
+	if owner != opts.From {
+		fmt.Printf("Account %s does not own name %s; it is owned by %s\n", opts.From.Hex(), name, owner.Hex())
+		os.Exit(1)
+	}
+	return registry
+}
+
+// finish reports the outcome of sending tx, waiting for it to be mined if the
+// flags ask for it.
+func (tf *txFlags) finish(client *failover.Client, tx *types.Transaction, err error) {
+	if err != nil {
+		fmt.Printf("Error sending transaction: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Sent transaction %s\n", tx.Hash().Hex())
+
+	if *tf.confirmations <= 0 {
+		return
+	}
+	ok, err := waitForReceipts(context.Background(), client, []*types.Transaction{tx}, *tf.confirmations)
+	if err != nil {
+		fmt.Printf("Error waiting for transaction: %s\n", err)
+		os.Exit(1)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// parseAddressArg parses a command line argument as an address, exiting with an
+// error if it isn't one.
+func parseAddressArg(arg string) common.Address {
+	if !common.IsHexAddress(arg) {
+		fmt.Printf("%s is not a hex address\n", arg)
+		os.Exit(1)
+	}
+	return common.HexToAddress(arg)
+}
+
+func setOwner(client *failover.Client, args []string) {
+	setOwnerFlagSet.Parse(args)
+	args = setOwnerFlagSet.Args()
+
+	if len(args) != 2 {
+		fmt.Println("usage: ensdns set-owner [flags] <name> <owner>")
+		os.Exit(1)
+	}
+	name, owner := dns.Fqdn(args[0]), parseAddressArg(args[1])
+
+	registry := setOwnerFlags.openOwnedRegistry(client, name)
+	tx, err := registry.SetOwner(name, owner)
+	setOwnerFlags.finish(client, tx, err)
+}
+
+func setSubnodeOwner(client *failover.Client, args []string) {
+	setSubnodeOwnerFlagSet.Parse(args)
+	args = setSubnodeOwnerFlagSet.Args()
+
+	if len(args) != 3 {
+		fmt.Println("usage: ensdns set-subnode-owner [flags] <name> <label> <owner>")
+		os.Exit(1)
+	}
+	name, label, owner := dns.Fqdn(args[0]), args[1], parseAddressArg(args[2])
+
+	registry := setSubnodeOwnerFlags.openOwnedRegistry(client, name)
+	tx, err := registry.SetSubnodeOwner(name, label, owner)
+	setSubnodeOwnerFlags.finish(client, tx, err)
+}
+
+func setResolver(client *failover.Client, args []string) {
+	setResolverFlagSet.Parse(args)
+	args = setResolverFlagSet.Args()
+
+	if len(args) != 2 {
+		fmt.Println("usage: ensdns set-resolver [flags] <name> <resolver>")
+		os.Exit(1)
+	}
+	name, resolver := dns.Fqdn(args[0]), parseAddressArg(args[1])
+
+	registry := setResolverFlags.openOwnedRegistry(client, name)
+	tx, err := registry.SetResolver(name, resolver)
+	setResolverFlags.finish(client, tx, err)
+}
+
+func setTTL(client *failover.Client, args []string) {
+	setTTLFlagSet.Parse(args)
+	args = setTTLFlagSet.Args()
+
+	if len(args) != 2 {
+		fmt.Println("usage: ensdns set-ttl [flags] <name> <ttl>")
+		os.Exit(1)
+	}
+	name := dns.Fqdn(args[0])
+	ttl, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		fmt.Printf("Error parsing TTL: %s\n", err)
+		os.Exit(1)
+	}
+
+	registry := setTTLFlags.openOwnedRegistry(client, name)
+	tx, err := registry.SetTTL(name, ttl)
+	setTTLFlags.finish(client, tx, err)
+}
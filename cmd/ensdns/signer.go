@@ -0,0 +1,236 @@
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	ethutils "github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// Environment variables that can be used instead of passing secrets as flags
+	privateKeyEnv = "ENSDNS_PRIVATE_KEY"
+	passwordEnv   = "ENSDNS_PASSWORD"
+)
+
+// Signer signs transactions on behalf of a single account.
+type Signer interface {
+	Address() common.Address
+	// Sign is a bind.SignerFn.
+	Sign(signer types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error)
+}
+
+// signerFlags are the flags that select how a command signs transactions.
+type signerFlags struct {
+	keystore *string
+	account  *string
+	password *string
+	keyfile  *string
+	external *string
+}
+
+func newSignerFlags(fs *flag.FlagSet) *signerFlags {
+	return &signerFlags{
+		keystore: fs.String("keystore", "", "Path to keystore"),
+		account:  fs.String("account", "0", "Account to use to send transactions: an address, or an index into the keystore or external signer's accounts"),
+		password: fs.String("password", "", "Password to unlock account with (default $"+passwordEnv+")"),
+		keyfile:  fs.String("keyfile", "", "Path to a file holding a hex-encoded private key to sign with (default $"+privateKeyEnv+")"),
+		external: fs.String("signer", "", "URL of an external signer, such as clef, to sign with"),
+	}
+}
+
+// getSigner returns the signer selected by flags, or by $ENSDNS_PRIVATE_KEY if
+// none of --signer, --keyfile and --keystore are given. If unlock is false, the
+// signer only needs to report its address; keystore accounts are left locked.
+func getSigner(flags *signerFlags, unlock bool) (Signer, error) {
+	switch {
+	case *flags.external != "":
+		return newExternalSigner(*flags.external, *flags.account)
+	case *flags.keyfile != "":
+		key, err := crypto.LoadECDSA(*flags.keyfile)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading private key: %s", err)
+		}
+		return keySigner{key}, nil
+	case *flags.keystore != "":
+		return newKeystoreSigner(*flags.keystore, *flags.account, *flags.password, unlock)
+	case os.Getenv(privateKeyEnv) != "":
+		// Only used when no flag selects a signer, so a key left in the
+		// environment can't override one given explicitly
+		key, err := crypto.HexToECDSA(strings.TrimPrefix(os.Getenv(privateKeyEnv), "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing $%s: %s", privateKeyEnv, err)
+		}
+		return keySigner{key}, nil
+	}
+	return nil, fmt.Errorf("One of --keystore, --keyfile, --signer or $%s is required", privateKeyEnv)
+}
+
+// getAddress returns the address of the account selected by flags, without
+// needing its key. An address passed as --account is used as is.
+func getAddress(flags *signerFlags) (common.Address, error) {
+	if common.IsHexAddress(*flags.account) {
+		return common.HexToAddress(*flags.account), nil
+	}
+	signer, err := getSigner(flags, false)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return signer.Address(), nil
+}
+
+// keystoreSigner signs with an account in a local keystore directory.
+type keystoreSigner struct {
+	accman  *accounts.Manager
+	account accounts.Account
+}
+
+func newKeystoreSigner(keystore, account, password string, unlock bool) (*keystoreSigner, error) {
+	accman := accounts.NewManager(keystore, 0, 0)
+	acct, err := ethutils.MakeAddress(accman, account)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing account: %s", err)
+	}
+	if !unlock {
+		return &keystoreSigner{accman, acct}, nil
+	}
+
+	if password == "" {
+		password = os.Getenv(passwordEnv)
+	}
+	if err := accman.Unlock(acct, password); err != nil {
+		return nil, fmt.Errorf("Error unlocking account: %s", err)
+	}
+	return &keystoreSigner{accman, acct}, nil
+}
+
+func (ks *keystoreSigner) Address() common.Address {
+	return ks.account.Address
+}
+
+func (ks *keystoreSigner) Sign(signer types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	signature, err := ks.accman.Sign(address, signer.Hash(tx).Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	return tx.WithSignature(signer, signature)
+}
+
+// keySigner signs with a raw private key.
+type keySigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func (ks keySigner) Address() common.Address {
+	return crypto.PubkeyToAddress(ks.key.PublicKey)
+}
+
+func (ks keySigner) Sign(signer types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	if address != ks.Address() {
+		return nil, fmt.Errorf("Cannot sign for account %s with key for %s", address.Hex(), ks.Address().Hex())
+	}
+	return types.SignTx(tx, signer, ks.key)
+}
+
+// externalSigner asks a separate process, such as clef, to sign transactions
+// over JSON-RPC. The signer may keep its keys on a hardware wallet, and may ask
+// its operator to approve each transaction.
+type externalSigner struct {
+	client  *rpc.Client
+	address common.Address
+}
+
+func newExternalSigner(url, account string) (*externalSigner, error) {
+	client, err := rpc.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to external signer: %s", err)
+	}
+
+	if common.IsHexAddress(account) {
+		return &externalSigner{client, common.HexToAddress(account)}, nil
+	}
+
+	index, err := strconv.Atoi(account)
+	if err != nil || index < 0 {
+		return nil, fmt.Errorf("Error parsing account: %s is not an address or index", account)
+	}
+	var addresses []common.Address
+	if err := client.Call(&addresses, "account_list"); err != nil {
+		return nil, fmt.Errorf("Error listing external signer's accounts: %s", err)
+	}
+	if index >= len(addresses) {
+		return nil, fmt.Errorf("Error parsing account: external signer has only %d accounts", len(addresses))
+	}
+	return &externalSigner{client, addresses[index]}, nil
+}
+
+func (es *externalSigner) Address() common.Address {
+	return es.address
+}
+
+func (es *externalSigner) Sign(signer types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	var result struct {
+		Raw hexutil.Bytes `json:"raw"`
+	}
+	if err := es.client.Call(&result, "account_signTransaction", newUnsignedTx(address, tx)); err != nil {
+		return nil, fmt.Errorf("Error signing with external signer: %s", err)
+	}
+
+	signed := new(types.Transaction)
+	if err := rlp.DecodeBytes(result.Raw, signed); err != nil {
+		return nil, fmt.Errorf("Error decoding transaction from external signer: %s", err)
+	}
+
+	// The external signer chooses how to sign, so check it signed what we asked
+	if signed.Protected() {
+		signer = types.NewEIP155Signer(signed.ChainId())
+	}
+	from, err := types.Sender(signer, signed)
+	if err != nil {
+		return nil, fmt.Errorf("Error checking transaction from external signer: %s", err)
+	}
+	if from != address || !sameTransaction(signed, tx) {
+		return nil, fmt.Errorf("External signer returned a different transaction than requested")
+	}
+	return signed, nil
+}
+
+// sameTransaction returns true if a and b differ at most in their signatures.
+func sameTransaction(a, b *types.Transaction) bool {
+	if (a.To() == nil) != (b.To() == nil) || (a.To() != nil && *a.To() != *b.To()) {
+		return false
+	}
+	return a.Nonce() == b.Nonce() &&
+		a.Gas().Cmp(b.Gas()) == 0 &&
+		a.GasPrice().Cmp(b.GasPrice()) == 0 &&
+		a.Value().Cmp(b.Value()) == 0 &&
+		bytes.Equal(a.Data(), b.Data())
+}
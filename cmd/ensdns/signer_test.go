@@ -0,0 +1,166 @@
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestGetSignerPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ensdns-signer")
+	if err != nil {
+		t.Fatalf("Error creating temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	envKey, _ := crypto.GenerateKey()
+	fileKey, _ := crypto.GenerateKey()
+	keyfile := filepath.Join(dir, "key")
+	if err := crypto.SaveECDSA(keyfile, fileKey); err != nil {
+		t.Fatalf("Error saving key: %s", err)
+	}
+	defer os.Setenv(privateKeyEnv, os.Getenv(privateKeyEnv))
+	os.Setenv(privateKeyEnv, hex.EncodeToString(crypto.FromECDSA(envKey)))
+
+	newFlags := func(args ...string) *signerFlags {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags := newSignerFlags(fs)
+		if err := fs.Parse(args); err != nil {
+			t.Fatalf("Error parsing flags: %s", err)
+		}
+		return flags
+	}
+
+	// The environment is only used when no flag selects a signer
+	signer, err := getSigner(newFlags(), false)
+	if err != nil {
+		t.Fatalf("Error getting signer: %s", err)
+	}
+	if want := crypto.PubkeyToAddress(envKey.PublicKey); signer.Address() != want {
+		t.Errorf("Got address %s from $%s, want %s", signer.Address().Hex(), privateKeyEnv, want.Hex())
+	}
+
+	signer, err = getSigner(newFlags("-keyfile", keyfile), false)
+	if err != nil {
+		t.Fatalf("Error getting signer: %s", err)
+	}
+	if want := crypto.PubkeyToAddress(fileKey.PublicKey); signer.Address() != want {
+		t.Errorf("Got address %s with -keyfile, want %s", signer.Address().Hex(), want.Hex())
+	}
+
+	account := crypto.PubkeyToAddress(fileKey.PublicKey).Hex()
+	signer, err = getSigner(newFlags("-keystore", dir, "-account", account), false)
+	if err != nil {
+		t.Fatalf("Error getting signer: %s", err)
+	}
+	if _, ok := signer.(*keystoreSigner); !ok {
+		t.Errorf("Got %T with -keystore, want a keystore signer", signer)
+	}
+}
+
+// FakeSigner answers account_signTransaction as an external signer would,
+// signing with key after applying tamper, if set, to the requested transaction.
+type FakeSigner struct {
+	key    *ecdsa.PrivateKey
+	tamper func(*unsignedTx)
+}
+
+func (fs *FakeSigner) SignTransaction(raw json.RawMessage) (map[string]hexutil.Bytes, error) {
+	var args unsignedTx
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	if fs.tamper != nil {
+		fs.tamper(&args)
+	}
+	var tx *types.Transaction
+	gas := new(big.Int).SetUint64(uint64(args.Gas))
+	if args.To == nil {
+		tx = types.NewContractCreation(uint64(args.Nonce), args.Value.ToInt(), gas, args.GasPrice.ToInt(), args.Data)
+	} else {
+		tx = types.NewTransaction(uint64(args.Nonce), *args.To, args.Value.ToInt(), gas, args.GasPrice.ToInt(), args.Data)
+	}
+	signed, err := types.SignTx(tx, types.HomesteadSigner{}, fs.key)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := rlp.EncodeToBytes(signed)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]hexutil.Bytes{"raw": encoded}, nil
+}
+
+func TestExternalSigner(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	fake := &FakeSigner{key: key}
+	server := rpc.NewServer()
+	if err := server.RegisterName("account", fake); err != nil {
+		t.Fatalf("Error registering fake signer: %s", err)
+	}
+	signer := &externalSigner{rpc.DialInProc(server), crypto.PubkeyToAddress(key.PublicKey)}
+
+	to := common.HexToAddress("0x1234")
+	call := types.NewTransaction(1, to, big.NewInt(0), big.NewInt(100000), big.NewInt(20), []byte{1, 2, 3})
+	create := types.NewContractCreation(2, big.NewInt(0), big.NewInt(1000000), big.NewInt(20), []byte{4, 5, 6})
+	for _, test := range []struct {
+		what   string
+		tx     *types.Transaction
+		tamper func(*unsignedTx)
+		ok     bool
+	}{
+		{"call", call, nil, true},
+		{"contract creation", create, nil, true},
+		{"changed recipient", call, func(tx *unsignedTx) { tx.To = &common.Address{} }, false},
+		{"creation turned into call", create, func(tx *unsignedTx) { tx.To = &to }, false},
+		{"changed nonce", call, func(tx *unsignedTx) { tx.Nonce++ }, false},
+		{"changed value", call, func(tx *unsignedTx) { tx.Value = (*hexutil.Big)(big.NewInt(1)) }, false},
+		{"changed gas", call, func(tx *unsignedTx) { tx.Gas++ }, false},
+		{"changed gas price", call, func(tx *unsignedTx) { tx.GasPrice = (*hexutil.Big)(big.NewInt(21)) }, false},
+		{"changed data", call, func(tx *unsignedTx) { tx.Data = []byte{1, 2} }, false},
+	} {
+		fake.tamper = test.tamper
+		signed, err := signer.Sign(types.HomesteadSigner{}, signer.Address(), test.tx)
+		if !test.ok {
+			if err == nil {
+				t.Errorf("%s: Expected an error", test.what)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Error signing: %s", test.what, err)
+			continue
+		}
+		if signed.Hash() == test.tx.Hash() || !sameTransaction(signed, test.tx) {
+			t.Errorf("%s: Got %v, want %v signed", test.what, signed, test.tx)
+		}
+	}
+}
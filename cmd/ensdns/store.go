@@ -0,0 +1,88 @@
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// storedZone is the on-disk representation of a fetched zone.
+type storedZone struct {
+	Registry common.Address
+	Root     string
+	Resolver common.Address
+	Block    uint64
+	Expires  time.Time
+	Data     []byte // Records, packed as returned by the resolver
+}
+
+// zoneStore persists fetched zones, so a restarted server can repopulate its
+// cache without refetching every zone from Ethereum.
+type zoneStore struct {
+	db *leveldb.DB
+}
+
+func openZoneStore(path string) (*zoneStore, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &zoneStore{db}, nil
+}
+
+func (zs *zoneStore) Put(zone storedZone) error {
+	data, err := json.Marshal(zone)
+	if err != nil {
+		return err
+	}
+	key := zoneCacheKey{zone.Registry, zone.Root}
+	return zs.db.Put([]byte(key.String()), data, nil)
+}
+
+// ForEach calls fn for every unexpired zone in the store. Expired and unreadable
+// entries are deleted.
+func (zs *zoneStore) ForEach(fn func(storedZone)) error {
+	return zs.Prune(func(zone storedZone) bool {
+		fn(zone)
+		return true
+	})
+}
+
+// Prune deletes expired and unreadable entries from the store, and any
+// unexpired zone for which keep returns false.
+func (zs *zoneStore) Prune(keep func(storedZone) bool) error {
+	now := time.Now()
+	it := zs.db.NewIterator(nil, nil)
+	defer it.Release()
+	for it.Next() {
+		var zone storedZone
+		if err := json.Unmarshal(it.Value(), &zone); err == nil && now.Before(zone.Expires) && keep(zone) {
+			continue
+		}
+		if err := zs.db.Delete(it.Key(), nil); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+func (zs *zoneStore) Close() error {
+	return zs.db.Close()
+}
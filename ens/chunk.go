@@ -0,0 +1,215 @@
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package ens
+
+import (
+    "fmt"
+    "math/big"
+    "strconv"
+
+    "github.com/miekg/dns"
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/crypto"
+    "github.com/ethereum/go-ethereum/params"
+    "golang.org/x/net/context"
+)
+
+// Zones too large to store in a single transaction are split into chunks. The
+// first chunk is stored under the zone's own node as usual, and ends with a
+// marker record giving the total number of chunks. Chunk i is stored under the
+// subnode _dnsrr<i> of the zone, which the zone's owner must also own; labels
+// owned by anyone else are never taken over.
+//
+// Chunks are written one transaction at a time, the first chunk last, so a
+// zone being replaced is never updated atomically: until every transaction is
+// mined, readers may see some chunks of the new zone alongside old ones.
+
+// MaxChunkSize is the largest number of bytes of records written in a single
+// transaction.
+var MaxChunkSize = 4096
+
+const chunkLabel = "_dnsrr"
+
+// chunkMarker returns the record that ends the first chunk of a zone stored in
+// count chunks. Its name is outside every zone, so it can never be served.
+func chunkMarker(count int) dns.RR {
+    return &dns.TXT{
+        Hdr: dns.RR_Header{Name: chunkLabel + ".", Rrtype: dns.TypeTXT, Class: dns.ClassNONE},
+        Txt: []string{strconv.Itoa(count)},
+    }
+}
+
+// chunkCount returns the number of chunks indicated by rr if it's a chunk marker.
+func chunkCount(rr dns.RR) (int, bool) {
+    txt, ok := rr.(*dns.TXT)
+    if !ok || txt.Hdr.Class != dns.ClassNONE || txt.Hdr.Name != chunkLabel + "." || len(txt.Txt) != 1 {
+        return 0, false
+    }
+    count, err := strconv.Atoi(txt.Txt[0])
+    if err != nil {
+        return 0, false
+    }
+    return count, true
+}
+
+func chunkLabelHash(i int) common.Hash {
+    return crypto.Keccak256Hash([]byte(chunkLabel + strconv.Itoa(i)))
+}
+
+// chunkNode returns the node chunk i of the resolver's zone is stored under.
+func (res *Resolver) chunkNode(i int) common.Hash {
+    if i == 0 {
+        return res.node
+    }
+    label := chunkLabelHash(i)
+    return crypto.Keccak256Hash(res.node[:], label[:])
+}
+
+// packChunks packs rrs into one or more chunks of at most size bytes each.
+func packChunks(rrs []dns.RR, size int) ([][]byte, error) {
+    rdata, err := PackRRs(rrs)
+    if err != nil {
+        return nil, err
+    }
+    if len(rdata) <= size {
+        return [][]byte{rdata}, nil
+    }
+
+    // Group records using their uncompressed sizes, which are an upper bound on
+    // their packed sizes. The first chunk leaves space for the marker.
+    buf := make([]byte, dns.MaxMsgSize)
+    markerLen, err := dns.PackRR(chunkMarker(1 << 30), buf, 0, nil, false)
+    if err != nil {
+        return nil, err
+    }
+
+    var groups [][]dns.RR
+    var group []dns.RR
+    limit, used := size - markerLen, 0
+    for _, rr := range rrs {
+        n, err := dns.PackRR(rr, buf, 0, nil, false)
+        if err != nil {
+            return nil, err
+        }
+        if n > size - markerLen {
+            return nil, fmt.Errorf("Record is too large to store: %v", rr)
+        }
+        if used + n > limit {
+            groups = append(groups, group)
+            group, limit, used = nil, size, 0
+        }
+        group = append(group, rr)
+        used += n
+    }
+    groups = append(groups, group)
+    groups[0] = append(groups[0], chunkMarker(len(groups)))
+
+    chunks := make([][]byte, len(groups))
+    for i, group := range groups {
+        if chunks[i], err = PackRRs(group); err != nil {
+            return nil, err
+        }
+    }
+    return chunks, nil
+}
+
+// readChunks fetches the remaining chunks of a zone whose first chunk holds rrs,
+// and returns all the zone's records.
+func (res *Resolver) readChunks(ctx context.Context, rrs []dns.RR) ([]dns.RR, error) {
+    if len(rrs) == 0 {
+        return rrs, nil
+    }
+    count, ok := chunkCount(rrs[len(rrs) - 1])
+    if !ok {
+        return rrs, nil
+    }
+    rrs = rrs[:len(rrs) - 1]
+
+    opts := res.resolver.CallOpts
+    opts.Context = ctx
+    for i := 1; i < count; i++ {
+        rdata, err := res.resolver.Contract.Dnsrr(&opts, res.chunkNode(i))
+        if err != nil {
+            return nil, err
+        }
+        chunk, err := UnpackRRs(rdata)
+        if err != nil {
+            return nil, err
+        }
+        rrs = append(rrs, chunk...)
+    }
+    return rrs, nil
+}
+
+// unclaimedChunks returns the indexes of the chunks, out of the first count,
+// that are stored under nodes the account doesn't own yet. It fails if any of
+// those nodes already belongs to another account.
+func (res *Resolver) unclaimedChunks(ctx context.Context, count int) ([]int, error) {
+    reg := res.registry
+    opts := reg.ens.CallOpts
+    opts.Context = ctx
+
+    var unclaimed []int
+    for i := 1; i < count; i++ {
+        owner, err := reg.ens.Contract.Owner(&opts, res.chunkNode(i))
+        if err != nil {
+            return nil, err
+        }
+        switch owner {
+        case reg.ens.TransactOpts.From:
+        case common.Address{}:
+            unclaimed = append(unclaimed, i)
+        default:
+            return nil, fmt.Errorf("Node for chunk %d is owned by %s", i, owner.Hex())
+        }
+    }
+    return unclaimed, nil
+}
+
+// claimChunks makes the account the owner of the nodes the chunks with the given
+// indexes are stored under. It doesn't wait for the transactions to be mined, so
+// they can be signed or sent elsewhere along with the writes that follow them.
+func (res *Resolver) claimChunks(ctx context.Context, unclaimed []int) ([]*types.Transaction, error) {
+    reg := res.registry
+    opts := reg.ens.TransactOpts
+    opts.Context = ctx
+
+    txs := make([]*types.Transaction, 0, len(unclaimed))
+    for _, i := range unclaimed {
+        tx, err := reg.ens.Contract.SetSubnodeOwner(&opts, res.node, chunkLabelHash(i), opts.From)
+        if err != nil {
+            return txs, fmt.Errorf("Error claiming node for chunk %d: %v", i, err)
+        }
+        txs = append(txs, tx)
+    }
+    return txs, nil
+}
+
+// estimateUnclaimed returns an upper bound on the gas needed to write chunk to a
+// node the account doesn't own yet. The resolver rejects such writes until the
+// node is claimed, so they can't be estimated directly; instead this estimates
+// the same write to the zone's own node, allowing for every storage slot the
+// chunk occupies being newly set rather than changed.
+func (res *Resolver) estimateUnclaimed(ctx context.Context, chunk []byte) (*big.Int, error) {
+    gas, err := res.estimate(ctx, resolverABI, "setDnsrr", res.node, chunk)
+    if err != nil {
+        return nil, err
+    }
+    slots := big.NewInt(int64((len(chunk) + 31) / 32 + 1))
+    extra := new(big.Int).Sub(params.SstoreSetGas, params.SstoreResetGas)
+    return gas.Add(gas, extra.Mul(extra, slots)), nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package ens
+
+import (
+    "fmt"
+    "math/big"
+    "testing"
+
+    "github.com/miekg/dns"
+    "github.com/ethereum/go-ethereum/accounts/abi/bind"
+    "github.com/ethereum/go-ethereum/common"
+    "golang.org/x/net/context"
+)
+
+// testZone returns a zone for example. with n TXT records besides its SOA.
+func testZone(t *testing.T, n int) []dns.RR {
+    zone := []string{"example. 300 IN SOA ns.example. hostmaster.example. 1 3600 60 3600 60"}
+    for i := 0; i < n; i++ {
+        zone = append(zone, fmt.Sprintf("r%d.example. 300 IN TXT \"record number %d of the test zone\"", i, i))
+    }
+    return parseRRs(t, zone...)
+}
+
+func TestPackChunks(t *testing.T) {
+    rrs := testZone(t, 60)
+    for _, size := range []int{256, 512, 4096, 65536} {
+        chunks, err := packChunks(rrs, size)
+        if err != nil {
+            t.Fatalf("size %d: Error packing chunks: %s", size, err)
+        }
+
+        var got []dns.RR
+        for i, chunk := range chunks {
+            if len(chunk) > size {
+                t.Errorf("size %d: Chunk %d is %d bytes", size, i, len(chunk))
+            }
+            unpacked, err := UnpackRRs(chunk)
+            if err != nil {
+                t.Fatalf("size %d: Error unpacking chunk %d: %s", size, i, err)
+            }
+            got = append(got, unpacked...)
+        }
+
+        // Only zones split into several chunks have a marker, at the end of the first
+        if len(chunks) > 1 {
+            first, _ := UnpackRRs(chunks[0])
+            if count, ok := chunkCount(first[len(first) - 1]); !ok || count != len(chunks) {
+                t.Errorf("size %d: First chunk ends with %v, want a marker for %d chunks", size, first[len(first) - 1], len(chunks))
+            }
+            marker := len(first) - 1
+            got = append(got[:marker], got[marker + 1:]...)
+        }
+        checkRRs(t, got, rrs)
+    }
+}
+
+func TestSetRRsChunked(t *testing.T) {
+    defer func(size int) { MaxChunkSize = size }(MaxChunkSize)
+    MaxChunkSize = 512
+
+    te := newTestENS(t, false)
+    te.register("example.", te.resolver)
+
+    // A backend that can't wait for transactions, as when uploading offline
+    offline, err := New(struct{ bind.ContractBackend }{te.sim}, te.registry.Address, *te.auth)
+    if err != nil {
+        t.Fatalf("Error constructing ENS instance: %s", err)
+    }
+    resolver, err := offline.GetResolver("example.")
+    if err != nil {
+        t.Fatalf("Error getting resolver: %s", err)
+    }
+
+    // The second upload reuses the chunk nodes claimed by the first
+    claimed := 0
+    for _, n := range []int{40, 60} {
+        rrs := testZone(t, n)
+        chunks, claims, err := resolver.CountSetRRs(context.Background(), rrs)
+        if err != nil {
+            t.Fatalf("Error counting transactions: %s", err)
+        }
+        if chunks < 3 {
+            t.Fatalf("Zone of %d records is stored in %d chunks, want at least 3", n, chunks)
+        }
+        if want := chunks - 1 - claimed; claims != want {
+            t.Errorf("Got %d claims for %d chunks, want %d", claims, chunks, want)
+        }
+        claimed += claims
+
+        estimate, err := resolver.EstimateSetRRs(context.Background(), rrs)
+        if err != nil {
+            t.Fatalf("Error estimating gas: %s", err)
+        }
+
+        txs, err := resolver.SetRRs(rrs)
+        if err != nil {
+            t.Fatalf("Error setting RRs: %s", err)
+        }
+        if len(txs) != chunks + claims {
+            t.Errorf("Sent %d transactions, want %d", len(txs), chunks + claims)
+        }
+        te.sim.Commit()
+        used := new(big.Int)
+        for _, tx := range txs {
+            receipt, err := te.sim.TransactionReceipt(context.Background(), tx.Hash())
+            if err != nil {
+                t.Fatalf("Error getting receipt: %s", err)
+            }
+            if receipt.GasUsed.Cmp(tx.Gas()) >= 0 {
+                t.Errorf("Transaction %s failed", tx.Hash().Hex())
+            }
+            used.Add(used, receipt.GasUsed)
+        }
+        // Writes to unclaimed chunks are estimated generously, but not wildly
+        if estimate.Cmp(used) < 0 || estimate.Cmp(new(big.Int).Mul(used, big.NewInt(2))) > 0 {
+            t.Errorf("Estimated %v gas, but used %v", estimate, used)
+        }
+
+        got, err := resolver.GetRRs()
+        if err != nil {
+            t.Fatalf("Error getting RRs: %s", err)
+        }
+        checkRRs(t, got, rrs)
+    }
+}
+
+func TestSetRRsForeignChunk(t *testing.T) {
+    defer func(size int) { MaxChunkSize = size }(MaxChunkSize)
+    MaxChunkSize = 512
+
+    te := newTestENS(t, false)
+    te.register("example.", te.resolver)
+    other := common.HexToAddress("0x1234")
+    if _, err := te.registry.SetSubnodeOwner("example.", chunkLabel + "2", other); err != nil {
+        t.Fatalf("Error setting owner: %s", err)
+    }
+    te.sim.Commit()
+
+    resolver, err := te.registry.GetResolver("example.")
+    if err != nil {
+        t.Fatalf("Error getting resolver: %s", err)
+    }
+    txs, err := resolver.SetRRs(testZone(t, 40))
+    if err == nil {
+        t.Errorf("Expected an error storing a chunk under a node owned by %s", other.Hex())
+    }
+    if len(txs) != 0 {
+        t.Errorf("Sent %d transactions, want none", len(txs))
+    }
+
+    owner, err := te.registry.GetOwner(chunkLabel + "2.example.")
+    if err != nil {
+        t.Fatalf("Error getting owner: %s", err)
+    }
+    if owner != other {
+        t.Errorf("Chunk node is owned by %s, want %s", owner.Hex(), other.Hex())
+    }
+}
@@ -0,0 +1,217 @@
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// +build ignore
+
+// generate compiles a Solidity source with solc and writes Go bindings for the
+// contracts in it. It does the same job as abigen --sol, for sources that need
+// a newer compiler than abigen can drive: it uses solc's standard JSON
+// interface, and converts the ABIs it produces to the older format bind
+// expects.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+var (
+	solFlag  = flag.String("sol", "", "Solidity source to compile")
+	outFlag  = flag.String("out", "", "File to write the bindings to")
+	pkgFlag  = flag.String("pkg", "contract", "Package name for the bindings")
+	solcFlag = flag.String("solc", "solc", "solc executable to compile with")
+)
+
+type compilerInput struct {
+	Language string                       `json:"language"`
+	Sources  map[string]map[string]string `json:"sources"`
+	Settings map[string]interface{}       `json:"settings"`
+}
+
+type compilerOutput struct {
+	Errors []struct {
+		Severity         string `json:"severity"`
+		FormattedMessage string `json:"formattedMessage"`
+	} `json:"errors"`
+	Contracts map[string]map[string]struct {
+		ABI []abiEntry `json:"abi"`
+		EVM struct {
+			Bytecode struct {
+				Object string `json:"object"`
+			} `json:"bytecode"`
+		} `json:"evm"`
+	} `json:"contracts"`
+}
+
+type abiEntry struct {
+	Type            string   `json:"type"`
+	Name            string   `json:"name"`
+	Inputs          []abiArg `json:"inputs"`
+	Outputs         []abiArg `json:"outputs"`
+	StateMutability string   `json:"stateMutability"`
+	Anonymous       bool     `json:"anonymous"`
+}
+
+type abiArg struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Indexed bool   `json:"indexed"`
+}
+
+// legacyABI converts an ABI to the format older compilers produced, which has
+// constant and payable fields in place of stateMutability.
+func legacyABI(entries []abiEntry) (string, error) {
+	var legacy []map[string]interface{}
+	for _, entry := range entries {
+		out := map[string]interface{}{"type": entry.Type}
+		switch entry.Type {
+		case "function":
+			out["name"] = entry.Name
+			out["inputs"] = legacyArgs(entry.Inputs, false)
+			out["outputs"] = legacyArgs(entry.Outputs, false)
+			out["constant"] = entry.StateMutability == "view" || entry.StateMutability == "pure"
+			out["payable"] = entry.StateMutability == "payable"
+		case "constructor":
+			out["inputs"] = legacyArgs(entry.Inputs, false)
+		case "event":
+			out["name"] = entry.Name
+			out["inputs"] = legacyArgs(entry.Inputs, true)
+			out["anonymous"] = entry.Anonymous
+		case "fallback":
+			out["payable"] = entry.StateMutability == "payable"
+		default:
+			return "", fmt.Errorf("Unsupported ABI entry type %s", entry.Type)
+		}
+		legacy = append(legacy, out)
+	}
+
+	data, err := json.Marshal(legacy)
+	return string(data), err
+}
+
+func legacyArgs(args []abiArg, event bool) []map[string]interface{} {
+	out := []map[string]interface{}{}
+	for _, arg := range args {
+		a := map[string]interface{}{"name": arg.Name, "type": arg.Type}
+		if event {
+			a["indexed"] = arg.Indexed
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+func compile(path string) (*compilerOutput, error) {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	name := filepath.Base(path)
+
+	input, err := json.Marshal(compilerInput{
+		Language: "Solidity",
+		Sources:  map[string]map[string]string{name: {"content": string(source)}},
+		Settings: map[string]interface{}{
+			"evmVersion": "homestead",
+			"optimizer":  map[string]interface{}{"enabled": true, "runs": 200},
+			"outputSelection": map[string]interface{}{
+				"*": map[string][]string{"*": {"abi", "evm.bytecode.object"}},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(*solcFlag, "--standard-json")
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("Error running %s: %s", *solcFlag, err)
+	}
+
+	var output compilerOutput
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		return nil, fmt.Errorf("Error parsing compiler output: %s", err)
+	}
+	failed := false
+	for _, e := range output.Errors {
+		fmt.Fprint(os.Stderr, e.FormattedMessage)
+		if e.Severity == "error" {
+			failed = true
+		}
+	}
+	if failed {
+		return nil, fmt.Errorf("Error compiling %s", path)
+	}
+	return &output, nil
+}
+
+func main() {
+	flag.Parse()
+	if *solFlag == "" || *outFlag == "" {
+		fmt.Println("usage: go run generate.go -sol <source> -out <bindings> [flags]")
+		os.Exit(1)
+	}
+
+	output, err := compile(*solFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// Interfaces have no bytecode, and are left for the contracts they
+	// describe to bind.
+	contracts := output.Contracts[filepath.Base(*solFlag)]
+	var types []string
+	for name, contract := range contracts {
+		if contract.EVM.Bytecode.Object != "" {
+			types = append(types, name)
+		}
+	}
+	sort.Strings(types)
+
+	var abis, bins []string
+	for _, name := range types {
+		abi, err := legacyABI(contracts[name].ABI)
+		if err != nil {
+			fmt.Printf("Error converting ABI of %s: %s\n", name, err)
+			os.Exit(1)
+		}
+		abis = append(abis, abi)
+		bins = append(bins, "0x"+contracts[name].EVM.Bytecode.Object)
+	}
+
+	code, err := bind.Bind(types, abis, bins, *pkgFlag, bind.LangGo)
+	if err != nil {
+		fmt.Printf("Error generating bindings: %s\n", err)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(*outFlag, []byte(code), 0644); err != nil {
+		fmt.Printf("Error writing bindings: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,477 @@
+// This file is an automatically generated Go binding. Do not modify as any
+// change will likely be lost upon the next re-generation!
+
+package contract
+
+import (
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DNSResolverABI is the input ABI used to generate the binding from.
+const DNSResolverABI = "[{\"inputs\":[{\"name\":\"ensAddr\",\"type\":\"address\"}],\"type\":\"constructor\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"name\":\"node\",\"type\":\"bytes32\"},{\"indexed\":false,\"name\":\"qtype\",\"type\":\"uint16\"},{\"indexed\":false,\"name\":\"qclass\",\"type\":\"uint16\"},{\"indexed\":false,\"name\":\"index\",\"type\":\"uint32\"}],\"name\":\"DnsrrChanged\",\"type\":\"event\"},{\"constant\":true,\"inputs\":[{\"name\":\"node\",\"type\":\"bytes32\"}],\"name\":\"dnsrr\",\"outputs\":[{\"name\":\"\",\"type\":\"bytes\"}],\"payable\":false,\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"node\",\"type\":\"bytes32\"},{\"name\":\"rdata\",\"type\":\"bytes\"}],\"name\":\"setDnsrr\",\"outputs\":[],\"payable\":false,\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"interfaceID\",\"type\":\"bytes4\"}],\"name\":\"supportsInterface\",\"outputs\":[{\"name\":\"\",\"type\":\"bool\"}],\"payable\":false,\"type\":\"function\"}]"
+
+// DNSResolverBin is the compiled bytecode used for deploying new contracts.
+const DNSResolverBin = `0x608060405234801561001057600080fd5b5060405161067538038061067583398101604081905261002f91610054565b60008054600160a060020a031916600160a060020a0392909216919091179055610084565b60006020828403121561006657600080fd5b8151600160a060020a038116811461007d57600080fd5b9392505050565b6105e2806100936000396000f3fe608060405234801561001057600080fd5b506004361061005d577c0100000000000000000000000000000000000000000000000000000000600035046301ffc9a78114610062578063126a710e1461008a57806376196c88146100aa575b600080fd5b6100756100703660046102f5565b6100bf565b60405190151581526020015b60405180910390f35b61009d610098366004610326565b610128565b604051610081919061033f565b6100bd6100b836600461038d565b6101ca565b005b60007f01ffc9a700000000000000000000000000000000000000000000000000000000600160e060020a03198316148061012257507f126a710e00000000000000000000000000000000000000000000000000000000600160e060020a03198316145b92915050565b600081815260016020526040902080546060919061014590610409565b80601f016020809104026020016040519081016040528092919081815260200182805461017190610409565b80156101be5780601f10610193576101008083540402835291602001916101be565b820191906000526020600020905b8154815290600101906020018083116101a157829003601f168201915b50505050509050919050565b60008054604080516020019290925290517f02571be3000000000000000000000000000000000000000000000000000000008152600481018590528491339173ffffffffffffffffffffffffffffffffffffffff909116906302571be390602401602060405180830381600087803b15801561024557600080fd5b5060325a03f115801561025757600080fd5b50506040805160208101918290526102729350909150610446565b73ffffffffffffffffffffffffffffffffffffffff161461029257600080fd5b60008481526001602052604090206102ab8385836104e4565b506040805160008082526020820181905281830152905185917fa4058818e0b26682e38ecbe9e172201e8eeca69e9a0998313ce81c1b26099fc3919081900360600190a250505050565b60006020828403121561030757600080fd5b8135600160e060020a03198116811461031f57600080fd5b9392505050565b60006020828403121561033857600080fd5b5035919050565b600060208083528351808285015260005b8181101561036c57858101830151858201604001528201610350565b506000604082860101526040601f19601f8301168501019250505092915050565b6000806000604084860312156103a257600080fd5b83359250602084013567ffffffffffffffff808211156103c157600080fd5b818601915086601f8301126103d557600080fd5b8135818111156103e457600080fd5b8760208285010111156103f657600080fd5b6020830194508093505050509250925092565b60028104600182168061041d57607f821691505b6020821081036104405760e060020a634e487b7102600052602260045260246000fd5b50919050565b60006020828403121561045857600080fd5b815173ffffffffffffffffffffffffffffffffffffffff8116811461031f57600080fd5b60e060020a634e487b7102600052604160045260246000fd5b601f8211156104df576000818152602081206020601f860104810160208610156104bc5750805b6020601f860104820191505b818110156104db578281556001016104c8565b5050505b505050565b67ffffffffffffffff8311156104fc576104fc61047c565b6105108361050a8354610409565b83610495565b6000601f841160018114610548576000851561052c5750838201355b60028087026008880290910a60001904198216178455506105a5565b600083815260209020601f19861690835b828110156105795786850135825560209485019460019092019101610559565b508682101561059957858401356008601f89160260020a60001904191681555b50506001600286020183555b505050505056fea264697066735822122013e53bbe2014f5f3ee68457d85971d042242acd0abb9445e331ac281ec3c03a464736f6c63430008150033`
+
+// DeployDNSResolver deploys a new Ethereum contract, binding an instance of DNSResolver to it.
+func DeployDNSResolver(auth *bind.TransactOpts, backend bind.ContractBackend, ensAddr common.Address) (common.Address, *types.Transaction, *DNSResolver, error) {
+	parsed, err := abi.JSON(strings.NewReader(DNSResolverABI))
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	address, tx, contract, err := bind.DeployContract(auth, parsed, common.FromHex(DNSResolverBin), backend, ensAddr)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	return address, tx, &DNSResolver{DNSResolverCaller: DNSResolverCaller{contract: contract}, DNSResolverTransactor: DNSResolverTransactor{contract: contract}}, nil
+}
+
+// DNSResolver is an auto generated Go binding around an Ethereum contract.
+type DNSResolver struct {
+	DNSResolverCaller     // Read-only binding to the contract
+	DNSResolverTransactor // Write-only binding to the contract
+}
+
+// DNSResolverCaller is an auto generated read-only Go binding around an Ethereum contract.
+type DNSResolverCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// DNSResolverTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type DNSResolverTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// DNSResolverSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type DNSResolverSession struct {
+	Contract     *DNSResolver      // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// DNSResolverCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type DNSResolverCallerSession struct {
+	Contract *DNSResolverCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts      // Call options to use throughout this session
+}
+
+// DNSResolverTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type DNSResolverTransactorSession struct {
+	Contract     *DNSResolverTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts      // Transaction auth options to use throughout this session
+}
+
+// DNSResolverRaw is an auto generated low-level Go binding around an Ethereum contract.
+type DNSResolverRaw struct {
+	Contract *DNSResolver // Generic contract binding to access the raw methods on
+}
+
+// DNSResolverCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type DNSResolverCallerRaw struct {
+	Contract *DNSResolverCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// DNSResolverTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type DNSResolverTransactorRaw struct {
+	Contract *DNSResolverTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewDNSResolver creates a new instance of DNSResolver, bound to a specific deployed contract.
+func NewDNSResolver(address common.Address, backend bind.ContractBackend) (*DNSResolver, error) {
+	contract, err := bindDNSResolver(address, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &DNSResolver{DNSResolverCaller: DNSResolverCaller{contract: contract}, DNSResolverTransactor: DNSResolverTransactor{contract: contract}}, nil
+}
+
+// NewDNSResolverCaller creates a new read-only instance of DNSResolver, bound to a specific deployed contract.
+func NewDNSResolverCaller(address common.Address, caller bind.ContractCaller) (*DNSResolverCaller, error) {
+	contract, err := bindDNSResolver(address, caller, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &DNSResolverCaller{contract: contract}, nil
+}
+
+// NewDNSResolverTransactor creates a new write-only instance of DNSResolver, bound to a specific deployed contract.
+func NewDNSResolverTransactor(address common.Address, transactor bind.ContractTransactor) (*DNSResolverTransactor, error) {
+	contract, err := bindDNSResolver(address, nil, transactor)
+	if err != nil {
+		return nil, err
+	}
+	return &DNSResolverTransactor{contract: contract}, nil
+}
+
+// bindDNSResolver binds a generic wrapper to an already deployed contract.
+func bindDNSResolver(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(DNSResolverABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, transactor), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_DNSResolver *DNSResolverRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _DNSResolver.Contract.DNSResolverCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_DNSResolver *DNSResolverRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _DNSResolver.Contract.DNSResolverTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_DNSResolver *DNSResolverRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _DNSResolver.Contract.DNSResolverTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_DNSResolver *DNSResolverCallerRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _DNSResolver.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_DNSResolver *DNSResolverTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _DNSResolver.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_DNSResolver *DNSResolverTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _DNSResolver.Contract.contract.Transact(opts, method, params...)
+}
+
+// Dnsrr is a free data retrieval call binding the contract method 0x126a710e.
+//
+// Solidity: function dnsrr(node bytes32) constant returns(bytes)
+func (_DNSResolver *DNSResolverCaller) Dnsrr(opts *bind.CallOpts, node [32]byte) ([]byte, error) {
+	var (
+		ret0 = new([]byte)
+	)
+	out := ret0
+	err := _DNSResolver.contract.Call(opts, out, "dnsrr", node)
+	return *ret0, err
+}
+
+// Dnsrr is a free data retrieval call binding the contract method 0x126a710e.
+//
+// Solidity: function dnsrr(node bytes32) constant returns(bytes)
+func (_DNSResolver *DNSResolverSession) Dnsrr(node [32]byte) ([]byte, error) {
+	return _DNSResolver.Contract.Dnsrr(&_DNSResolver.CallOpts, node)
+}
+
+// Dnsrr is a free data retrieval call binding the contract method 0x126a710e.
+//
+// Solidity: function dnsrr(node bytes32) constant returns(bytes)
+func (_DNSResolver *DNSResolverCallerSession) Dnsrr(node [32]byte) ([]byte, error) {
+	return _DNSResolver.Contract.Dnsrr(&_DNSResolver.CallOpts, node)
+}
+
+// SupportsInterface is a free data retrieval call binding the contract method 0x01ffc9a7.
+//
+// Solidity: function supportsInterface(interfaceID bytes4) constant returns(bool)
+func (_DNSResolver *DNSResolverCaller) SupportsInterface(opts *bind.CallOpts, interfaceID [4]byte) (bool, error) {
+	var (
+		ret0 = new(bool)
+	)
+	out := ret0
+	err := _DNSResolver.contract.Call(opts, out, "supportsInterface", interfaceID)
+	return *ret0, err
+}
+
+// SupportsInterface is a free data retrieval call binding the contract method 0x01ffc9a7.
+//
+// Solidity: function supportsInterface(interfaceID bytes4) constant returns(bool)
+func (_DNSResolver *DNSResolverSession) SupportsInterface(interfaceID [4]byte) (bool, error) {
+	return _DNSResolver.Contract.SupportsInterface(&_DNSResolver.CallOpts, interfaceID)
+}
+
+// SupportsInterface is a free data retrieval call binding the contract method 0x01ffc9a7.
+//
+// Solidity: function supportsInterface(interfaceID bytes4) constant returns(bool)
+func (_DNSResolver *DNSResolverCallerSession) SupportsInterface(interfaceID [4]byte) (bool, error) {
+	return _DNSResolver.Contract.SupportsInterface(&_DNSResolver.CallOpts, interfaceID)
+}
+
+// SetDnsrr is a paid mutator transaction binding the contract method 0x76196c88.
+//
+// Solidity: function setDnsrr(node bytes32, rdata bytes) returns()
+func (_DNSResolver *DNSResolverTransactor) SetDnsrr(opts *bind.TransactOpts, node [32]byte, rdata []byte) (*types.Transaction, error) {
+	return _DNSResolver.contract.Transact(opts, "setDnsrr", node, rdata)
+}
+
+// SetDnsrr is a paid mutator transaction binding the contract method 0x76196c88.
+//
+// Solidity: function setDnsrr(node bytes32, rdata bytes) returns()
+func (_DNSResolver *DNSResolverSession) SetDnsrr(node [32]byte, rdata []byte) (*types.Transaction, error) {
+	return _DNSResolver.Contract.SetDnsrr(&_DNSResolver.TransactOpts, node, rdata)
+}
+
+// SetDnsrr is a paid mutator transaction binding the contract method 0x76196c88.
+//
+// Solidity: function setDnsrr(node bytes32, rdata bytes) returns()
+func (_DNSResolver *DNSResolverTransactorSession) SetDnsrr(node [32]byte, rdata []byte) (*types.Transaction, error) {
+	return _DNSResolver.Contract.SetDnsrr(&_DNSResolver.TransactOpts, node, rdata)
+}
+
+// RRSetResolverABI is the input ABI used to generate the binding from.
+const RRSetResolverABI = "[{\"inputs\":[{\"name\":\"ensAddr\",\"type\":\"address\"}],\"type\":\"constructor\"},{\"anonymous\":false,\"inputs\":[{\"indexed\":true,\"name\":\"node\",\"type\":\"bytes32\"},{\"indexed\":false,\"name\":\"qtype\",\"type\":\"uint16\"},{\"indexed\":false,\"name\":\"qclass\",\"type\":\"uint16\"},{\"indexed\":false,\"name\":\"index\",\"type\":\"uint32\"}],\"name\":\"DnsrrChanged\",\"type\":\"event\"},{\"constant\":true,\"inputs\":[{\"name\":\"node\",\"type\":\"bytes32\"},{\"name\":\"index\",\"type\":\"uint32\"}],\"name\":\"rrset\",\"outputs\":[{\"name\":\"\",\"type\":\"bytes\"}],\"payable\":false,\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"node\",\"type\":\"bytes32\"}],\"name\":\"rrsetCount\",\"outputs\":[{\"name\":\"\",\"type\":\"uint32\"}],\"payable\":false,\"type\":\"function\"},{\"constant\":false,\"inputs\":[{\"name\":\"node\",\"type\":\"bytes32\"},{\"name\":\"name\",\"type\":\"bytes32\"},{\"name\":\"qtype\",\"type\":\"uint16\"},{\"name\":\"qclass\",\"type\":\"uint16\"},{\"name\":\"rdata\",\"type\":\"bytes\"}],\"name\":\"setRRSet\",\"outputs\":[],\"payable\":false,\"type\":\"function\"},{\"constant\":true,\"inputs\":[{\"name\":\"interfaceID\",\"type\":\"bytes4\"}],\"name\":\"supportsInterface\",\"outputs\":[{\"name\":\"\",\"type\":\"bool\"}],\"payable\":false,\"type\":\"function\"}]"
+
+// RRSetResolverBin is the compiled bytecode used for deploying new contracts.
+const RRSetResolverBin = `0x608060405234801561001057600080fd5b50604051610c2f380380610c2f83398101604081905261002f91610054565b60008054600160a060020a031916600160a060020a0392909216919091179055610084565b60006020828403121561006657600080fd5b8151600160a060020a038116811461007d57600080fd5b9392505050565b610b9c806100936000396000f3fe608060405234801561001057600080fd5b5060043610610068577c0100000000000000000000000000000000000000000000000000000000600035046301ffc9a7811461006d5780632c8d61771461009557806347a60f99146100ca578063db411ad0146100df575b600080fd5b61008061007b366004610640565b6100ff565b60405190151581526020015b60405180910390f35b6100b56100a3366004610671565b60009081526001602052604090205490565b60405163ffffffff909116815260200161008c565b6100dd6100d83660046106a1565b610168565b005b6100f26100ed366004610747565b61051e565b60405161008c9190610780565b60007f01ffc9a700000000000000000000000000000000000000000000000000000000600160e060020a03198316148061016257507fb06a743e00000000000000000000000000000000000000000000000000000000600160e060020a03198316145b92915050565b60008054604080516020019290925290517f02571be3000000000000000000000000000000000000000000000000000000008152600481018890528791339173ffffffffffffffffffffffffffffffffffffffff909116906302571be390602401602060405180830381600087803b1580156101e357600080fd5b5060325a03f11580156101f557600080fd5b505060408051602081019182905261021093509091506107ce565b73ffffffffffffffffffffffffffffffffffffffff161461023057600080fd5b60008686866040516020016102819392919092835261ffff9182167e010000000000000000000000000000000000000000000000000000000000009081026020850152911602602282015260240190565b60408051601f19818403018152918152815160209283012060008b8152600184528281206002855283822083835290945291822054909350908590036103cb57806000036102d157505050610515565b8154811461037257815460009083906102ec90600190610804565b815481106102fc576102fc610828565b90600052602060002090600202019050808360018461031b9190610804565b8154811061032b5761032b610828565b600091825260209091208254600290920201908155600180820190610352908401826108fd565b50505060008b815260026020908152604080832093548352929052208190555b81805480610382576103826109da565b600082815260208120600260001990930192830201818155906103a860018301826105ea565b5050905560008a81526002602090815260408083208684529091528120556104b8565b8060000361047a5781604051806040016040528085815260200188888080601f016020809104026020016040519081016040528093929190818152602001838380828437600092018290525093909452505083546001818101865594825260209182902084516002909202019081559083015192939092908301915061045190826109f3565b5050825460008c8152600260209081526040808320888452909152902081905591506104b89050565b858583610488600185610804565b8154811061049857610498610828565b906000526020600020906002020160010191826104b6929190610aa9565b505b897fa4058818e0b26682e38ecbe9e172201e8eeca69e9a0998313ce81c1b26099fc389896104e7600186610804565b6040805161ffff948516815293909216602084015263ffffffff169082015260600160405180910390a25050505b50505050505050565b600082815260016020526040902080546060919063ffffffff841690811061054857610548610828565b9060005260206000209060020201600101805461056490610841565b80601f016020809104026020016040519081016040528092919081815260200182805461059090610841565b80156105dd5780601f106105b2576101008083540402835291602001916105dd565b820191906000526020600020905b8154815290600101906020018083116105c057829003601f168201915b5050505050905092915050565b5080546105f690610841565b6000825580601f10610606575050565b601f0160209004906000526020600020908101906106249190610627565b50565b5b8082111561063c5760008155600101610628565b5090565b60006020828403121561065257600080fd5b8135600160e060020a03198116811461066a57600080fd5b9392505050565b60006020828403121561068357600080fd5b5035919050565b803561ffff8116811461069c57600080fd5b919050565b60008060008060008060a087890312156106ba57600080fd5b86359550602087013594506106d16040880161068a565b93506106df6060880161068a565b9250608087013567ffffffffffffffff808211156106fc57600080fd5b818901915089601f83011261071057600080fd5b81358181111561071f57600080fd5b8a602082850101111561073157600080fd5b6020830194508093505050509295509295509295565b6000806040838503121561075a57600080fd5b82359150602083013563ffffffff8116811461077557600080fd5b809150509250929050565b600060208083528351808285015260005b818110156107ad57858101830151858201604001528201610791565b506000604082860101526040601f19601f8301168501019250505092915050565b6000602082840312156107e057600080fd5b815173ffffffffffffffffffffffffffffffffffffffff8116811461066a57600080fd5b818103818111156101625760e060020a634e487b7102600052601160045260246000fd5b60e060020a634e487b7102600052603260045260246000fd5b60028104600182168061085557607f821691505b6020821081036108785760e060020a634e487b7102600052602260045260246000fd5b50919050565b60e060020a634e487b7102600052604160045260246000fd5b601f8211156108e1576000818152602081206020601f860104810160208610156108be5750805b6020601f860104820191505b818110156108dd578281556001016108ca565b5050505b505050565b6002808302600893909302900a6000190419161790565b818103610908575050565b6109128254610841565b67ffffffffffffffff81111561092a5761092a61087e565b61093e816109388454610841565b84610897565b6000601f82116001811461096c576000831561095a5750848201545b61096484826108e6565b8555506109d3565b600085815260209020601f19841690600086815260209020845b838110156109a65782860154825560019586019590910190602001610986565b50858310156109c657818501546008601f88160260020a60001904191681555b5050506001600284020184555b5050505050565b60e060020a634e487b7102600052603160045260246000fd5b815167ffffffffffffffff811115610a0d57610a0d61087e565b610a1b816109388454610841565b602080601f831160018114610a4a5760008415610a385750858301515b610a4285826108e6565b8655506108dd565b600085815260208120601f198616915b82811015610a7957888601518255948401946001909101908401610a5a565b5085821015610a9957878501516008601f88160260020a60001904191681555b5050505050600202600101905550565b67ffffffffffffffff831115610ac157610ac161087e565b610ad583610acf8354610841565b83610897565b6000601f841160018114610b035760008515610af15750838201355b610afb86826108e6565b8455506109d3565b600083815260209020601f19861690835b82811015610b345786850135825560209485019460019092019101610b14565b5086821015610b5457858401356008601f89160260020a60001904191681555b5050600160028602018355505050505056fea2646970667358221220af923b7a97c3cf69e98f85306ed2b8cca40a0f62973f789cf044215f3778d3af64736f6c63430008150033`
+
+// DeployRRSetResolver deploys a new Ethereum contract, binding an instance of RRSetResolver to it.
+func DeployRRSetResolver(auth *bind.TransactOpts, backend bind.ContractBackend, ensAddr common.Address) (common.Address, *types.Transaction, *RRSetResolver, error) {
+	parsed, err := abi.JSON(strings.NewReader(RRSetResolverABI))
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	address, tx, contract, err := bind.DeployContract(auth, parsed, common.FromHex(RRSetResolverBin), backend, ensAddr)
+	if err != nil {
+		return common.Address{}, nil, nil, err
+	}
+	return address, tx, &RRSetResolver{RRSetResolverCaller: RRSetResolverCaller{contract: contract}, RRSetResolverTransactor: RRSetResolverTransactor{contract: contract}}, nil
+}
+
+// RRSetResolver is an auto generated Go binding around an Ethereum contract.
+type RRSetResolver struct {
+	RRSetResolverCaller     // Read-only binding to the contract
+	RRSetResolverTransactor // Write-only binding to the contract
+}
+
+// RRSetResolverCaller is an auto generated read-only Go binding around an Ethereum contract.
+type RRSetResolverCaller struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// RRSetResolverTransactor is an auto generated write-only Go binding around an Ethereum contract.
+type RRSetResolverTransactor struct {
+	contract *bind.BoundContract // Generic contract wrapper for the low level calls
+}
+
+// RRSetResolverSession is an auto generated Go binding around an Ethereum contract,
+// with pre-set call and transact options.
+type RRSetResolverSession struct {
+	Contract     *RRSetResolver    // Generic contract binding to set the session for
+	CallOpts     bind.CallOpts     // Call options to use throughout this session
+	TransactOpts bind.TransactOpts // Transaction auth options to use throughout this session
+}
+
+// RRSetResolverCallerSession is an auto generated read-only Go binding around an Ethereum contract,
+// with pre-set call options.
+type RRSetResolverCallerSession struct {
+	Contract *RRSetResolverCaller // Generic contract caller binding to set the session for
+	CallOpts bind.CallOpts        // Call options to use throughout this session
+}
+
+// RRSetResolverTransactorSession is an auto generated write-only Go binding around an Ethereum contract,
+// with pre-set transact options.
+type RRSetResolverTransactorSession struct {
+	Contract     *RRSetResolverTransactor // Generic contract transactor binding to set the session for
+	TransactOpts bind.TransactOpts        // Transaction auth options to use throughout this session
+}
+
+// RRSetResolverRaw is an auto generated low-level Go binding around an Ethereum contract.
+type RRSetResolverRaw struct {
+	Contract *RRSetResolver // Generic contract binding to access the raw methods on
+}
+
+// RRSetResolverCallerRaw is an auto generated low-level read-only Go binding around an Ethereum contract.
+type RRSetResolverCallerRaw struct {
+	Contract *RRSetResolverCaller // Generic read-only contract binding to access the raw methods on
+}
+
+// RRSetResolverTransactorRaw is an auto generated low-level write-only Go binding around an Ethereum contract.
+type RRSetResolverTransactorRaw struct {
+	Contract *RRSetResolverTransactor // Generic write-only contract binding to access the raw methods on
+}
+
+// NewRRSetResolver creates a new instance of RRSetResolver, bound to a specific deployed contract.
+func NewRRSetResolver(address common.Address, backend bind.ContractBackend) (*RRSetResolver, error) {
+	contract, err := bindRRSetResolver(address, backend, backend)
+	if err != nil {
+		return nil, err
+	}
+	return &RRSetResolver{RRSetResolverCaller: RRSetResolverCaller{contract: contract}, RRSetResolverTransactor: RRSetResolverTransactor{contract: contract}}, nil
+}
+
+// NewRRSetResolverCaller creates a new read-only instance of RRSetResolver, bound to a specific deployed contract.
+func NewRRSetResolverCaller(address common.Address, caller bind.ContractCaller) (*RRSetResolverCaller, error) {
+	contract, err := bindRRSetResolver(address, caller, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &RRSetResolverCaller{contract: contract}, nil
+}
+
+// NewRRSetResolverTransactor creates a new write-only instance of RRSetResolver, bound to a specific deployed contract.
+func NewRRSetResolverTransactor(address common.Address, transactor bind.ContractTransactor) (*RRSetResolverTransactor, error) {
+	contract, err := bindRRSetResolver(address, nil, transactor)
+	if err != nil {
+		return nil, err
+	}
+	return &RRSetResolverTransactor{contract: contract}, nil
+}
+
+// bindRRSetResolver binds a generic wrapper to an already deployed contract.
+func bindRRSetResolver(address common.Address, caller bind.ContractCaller, transactor bind.ContractTransactor) (*bind.BoundContract, error) {
+	parsed, err := abi.JSON(strings.NewReader(RRSetResolverABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(address, parsed, caller, transactor), nil
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_RRSetResolver *RRSetResolverRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _RRSetResolver.Contract.RRSetResolverCaller.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_RRSetResolver *RRSetResolverRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _RRSetResolver.Contract.RRSetResolverTransactor.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_RRSetResolver *RRSetResolverRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _RRSetResolver.Contract.RRSetResolverTransactor.contract.Transact(opts, method, params...)
+}
+
+// Call invokes the (constant) contract method with params as input values and
+// sets the output to result. The result type might be a single field for simple
+// returns, a slice of interfaces for anonymous returns and a struct for named
+// returns.
+func (_RRSetResolver *RRSetResolverCallerRaw) Call(opts *bind.CallOpts, result interface{}, method string, params ...interface{}) error {
+	return _RRSetResolver.Contract.contract.Call(opts, result, method, params...)
+}
+
+// Transfer initiates a plain transaction to move funds to the contract, calling
+// its default method if one is available.
+func (_RRSetResolver *RRSetResolverTransactorRaw) Transfer(opts *bind.TransactOpts) (*types.Transaction, error) {
+	return _RRSetResolver.Contract.contract.Transfer(opts)
+}
+
+// Transact invokes the (paid) contract method with params as input values.
+func (_RRSetResolver *RRSetResolverTransactorRaw) Transact(opts *bind.TransactOpts, method string, params ...interface{}) (*types.Transaction, error) {
+	return _RRSetResolver.Contract.contract.Transact(opts, method, params...)
+}
+
+// Rrset is a free data retrieval call binding the contract method 0xdb411ad0.
+//
+// Solidity: function rrset(node bytes32, index uint32) constant returns(bytes)
+func (_RRSetResolver *RRSetResolverCaller) Rrset(opts *bind.CallOpts, node [32]byte, index uint32) ([]byte, error) {
+	var (
+		ret0 = new([]byte)
+	)
+	out := ret0
+	err := _RRSetResolver.contract.Call(opts, out, "rrset", node, index)
+	return *ret0, err
+}
+
+// Rrset is a free data retrieval call binding the contract method 0xdb411ad0.
+//
+// Solidity: function rrset(node bytes32, index uint32) constant returns(bytes)
+func (_RRSetResolver *RRSetResolverSession) Rrset(node [32]byte, index uint32) ([]byte, error) {
+	return _RRSetResolver.Contract.Rrset(&_RRSetResolver.CallOpts, node, index)
+}
+
+// Rrset is a free data retrieval call binding the contract method 0xdb411ad0.
+//
+// Solidity: function rrset(node bytes32, index uint32) constant returns(bytes)
+func (_RRSetResolver *RRSetResolverCallerSession) Rrset(node [32]byte, index uint32) ([]byte, error) {
+	return _RRSetResolver.Contract.Rrset(&_RRSetResolver.CallOpts, node, index)
+}
+
+// RrsetCount is a free data retrieval call binding the contract method 0x2c8d6177.
+//
+// Solidity: function rrsetCount(node bytes32) constant returns(uint32)
+func (_RRSetResolver *RRSetResolverCaller) RrsetCount(opts *bind.CallOpts, node [32]byte) (uint32, error) {
+	var (
+		ret0 = new(uint32)
+	)
+	out := ret0
+	err := _RRSetResolver.contract.Call(opts, out, "rrsetCount", node)
+	return *ret0, err
+}
+
+// RrsetCount is a free data retrieval call binding the contract method 0x2c8d6177.
+//
+// Solidity: function rrsetCount(node bytes32) constant returns(uint32)
+func (_RRSetResolver *RRSetResolverSession) RrsetCount(node [32]byte) (uint32, error) {
+	return _RRSetResolver.Contract.RrsetCount(&_RRSetResolver.CallOpts, node)
+}
+
+// RrsetCount is a free data retrieval call binding the contract method 0x2c8d6177.
+//
+// Solidity: function rrsetCount(node bytes32) constant returns(uint32)
+func (_RRSetResolver *RRSetResolverCallerSession) RrsetCount(node [32]byte) (uint32, error) {
+	return _RRSetResolver.Contract.RrsetCount(&_RRSetResolver.CallOpts, node)
+}
+
+// SupportsInterface is a free data retrieval call binding the contract method 0x01ffc9a7.
+//
+// Solidity: function supportsInterface(interfaceID bytes4) constant returns(bool)
+func (_RRSetResolver *RRSetResolverCaller) SupportsInterface(opts *bind.CallOpts, interfaceID [4]byte) (bool, error) {
+	var (
+		ret0 = new(bool)
+	)
+	out := ret0
+	err := _RRSetResolver.contract.Call(opts, out, "supportsInterface", interfaceID)
+	return *ret0, err
+}
+
+// SupportsInterface is a free data retrieval call binding the contract method 0x01ffc9a7.
+//
+// Solidity: function supportsInterface(interfaceID bytes4) constant returns(bool)
+func (_RRSetResolver *RRSetResolverSession) SupportsInterface(interfaceID [4]byte) (bool, error) {
+	return _RRSetResolver.Contract.SupportsInterface(&_RRSetResolver.CallOpts, interfaceID)
+}
+
+// SupportsInterface is a free data retrieval call binding the contract method 0x01ffc9a7.
+//
+// Solidity: function supportsInterface(interfaceID bytes4) constant returns(bool)
+func (_RRSetResolver *RRSetResolverCallerSession) SupportsInterface(interfaceID [4]byte) (bool, error) {
+	return _RRSetResolver.Contract.SupportsInterface(&_RRSetResolver.CallOpts, interfaceID)
+}
+
+// SetRRSet is a paid mutator transaction binding the contract method 0x47a60f99.
+//
+// Solidity: function setRRSet(node bytes32, name bytes32, qtype uint16, qclass uint16, rdata bytes) returns()
+func (_RRSetResolver *RRSetResolverTransactor) SetRRSet(opts *bind.TransactOpts, node [32]byte, name [32]byte, qtype uint16, qclass uint16, rdata []byte) (*types.Transaction, error) {
+	return _RRSetResolver.contract.Transact(opts, "setRRSet", node, name, qtype, qclass, rdata)
+}
+
+// SetRRSet is a paid mutator transaction binding the contract method 0x47a60f99.
+//
+// Solidity: function setRRSet(node bytes32, name bytes32, qtype uint16, qclass uint16, rdata bytes) returns()
+func (_RRSetResolver *RRSetResolverSession) SetRRSet(node [32]byte, name [32]byte, qtype uint16, qclass uint16, rdata []byte) (*types.Transaction, error) {
+	return _RRSetResolver.Contract.SetRRSet(&_RRSetResolver.TransactOpts, node, name, qtype, qclass, rdata)
+}
+
+// SetRRSet is a paid mutator transaction binding the contract method 0x47a60f99.
+//
+// Solidity: function setRRSet(node bytes32, name bytes32, qtype uint16, qclass uint16, rdata bytes) returns()
+func (_RRSetResolver *RRSetResolverTransactorSession) SetRRSet(node [32]byte, name [32]byte, qtype uint16, qclass uint16, rdata []byte) (*types.Transaction, error) {
+	return _RRSetResolver.Contract.SetRRSet(&_RRSetResolver.TransactOpts, node, name, qtype, qclass, rdata)
+}
@@ -0,0 +1,185 @@
+// SPDX-License-Identifier: GPL-3.0-or-later
+//
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// These contracts need a newer compiler than ens.sol, so they're built by
+// generate.go rather than abigen. They target the homestead EVM, so they run
+// on any chain ensdns can talk to.
+pragma solidity 0.8.21;
+
+/**
+ * The parts of the ENS registry the resolvers use.
+ */
+interface ENS {
+    function owner(bytes32 node) external view returns (address);
+}
+
+/**
+ * A resolver that stores each name's DNS records, in packed wire format, for
+ * serving by ensdns. Only the owner of a node in the ENS registry may set its
+ * records.
+ */
+contract DNSResolver {
+    ENS ens;
+    mapping(bytes32=>bytes) records;
+
+    /**
+     * Emitted when a node's records are replaced. Since the whole zone is
+     * rewritten at once, qtype, qclass and index are always zero.
+     */
+    event DnsrrChanged(bytes32 indexed node, uint16 qtype, uint16 qclass, uint32 index);
+
+    modifier only_owner(bytes32 node) {
+        require(ens.owner(node) == msg.sender);
+        _;
+    }
+
+    /**
+     * Constructor.
+     * @param ensAddr The ENS registrar contract.
+     */
+    constructor(address ensAddr) {
+        ens = ENS(ensAddr);
+    }
+
+    /**
+     * Returns true if the resolver implements the interface specified by the provided hash.
+     * @param interfaceID The ID of the interface to check for.
+     * @return True if the contract implements the requested interface.
+     */
+    function supportsInterface(bytes4 interfaceID) public pure returns (bool) {
+        return interfaceID == 0x01ffc9a7 || interfaceID == 0x126a710e;
+    }
+
+    /**
+     * Returns the DNS records associated with an ENS node.
+     * @param node The ENS node to query.
+     * @return The node's records, in packed wire format.
+     */
+    function dnsrr(bytes32 node) public view returns (bytes memory) {
+        return records[node];
+    }
+
+    /**
+     * Sets the DNS records associated with an ENS node, replacing any already
+     * set. May only be called by the owner of that node in the ENS registry.
+     * @param node The node to update.
+     * @param rdata The records to set, in packed wire format.
+     */
+    function setDnsrr(bytes32 node, bytes calldata rdata) public only_owner(node) {
+        records[node] = rdata;
+        emit DnsrrChanged(node, 0, 0, 0);
+    }
+}
+
+/**
+ * A resolver that stores a zone's DNS records as separate RRsets, so that one
+ * RRset can be changed without rewriting the entire zone. Only the owner of a
+ * node in the ENS registry may change its RRsets.
+ *
+ * RRsets are identified by the hash of their owner name, their type and their
+ * class, and are stored in packed wire format. Each RRset occupies an index
+ * between 0 and rrsetCount(node) - 1; indexes may change as RRsets are removed.
+ */
+contract RRSetResolver {
+    struct RRSet {
+        bytes32 key;
+        bytes rdata;
+    }
+
+    ENS ens;
+    mapping(bytes32=>RRSet[]) rrsets;
+    // One more than the index of each RRset, so that zero means there is none
+    mapping(bytes32=>mapping(bytes32=>uint)) indexes;
+
+    /**
+     * Emitted when an RRset is added, replaced or removed. index is the index
+     * the RRset had before it was removed.
+     */
+    event DnsrrChanged(bytes32 indexed node, uint16 qtype, uint16 qclass, uint32 index);
+
+    modifier only_owner(bytes32 node) {
+        require(ens.owner(node) == msg.sender);
+        _;
+    }
+
+    /**
+     * Constructor.
+     * @param ensAddr The ENS registrar contract.
+     */
+    constructor(address ensAddr) {
+        ens = ENS(ensAddr);
+    }
+
+    /**
+     * Returns true if the resolver implements the interface specified by the provided hash.
+     * @param interfaceID The ID of the interface to check for.
+     * @return True if the contract implements the requested interface.
+     */
+    function supportsInterface(bytes4 interfaceID) public pure returns (bool) {
+        return interfaceID == 0x01ffc9a7 || interfaceID == 0xb06a743e;
+    }
+
+    /**
+     * Returns the number of RRsets stored for a node.
+     */
+    function rrsetCount(bytes32 node) public view returns (uint32) {
+        return uint32(rrsets[node].length);
+    }
+
+    /**
+     * Returns the RRset at the specified index.
+     */
+    function rrset(bytes32 node, uint32 index) public view returns (bytes memory) {
+        return rrsets[node][index].rdata;
+    }
+
+    /**
+     * Replaces an RRset, adding it if it doesn't exist. An empty rdata removes it.
+     * @param node The node of the zone the RRset belongs to.
+     * @param name The keccak256 hash of the RRset's lower-cased, fully qualified
+     *        owner name.
+     * @param qtype The RRset's type.
+     * @param qclass The RRset's class.
+     * @param rdata The RRset's records, in packed wire format.
+     */
+    function setRRSet(bytes32 node, bytes32 name, uint16 qtype, uint16 qclass, bytes calldata rdata) public only_owner(node) {
+        bytes32 key = keccak256(abi.encodePacked(name, qtype, qclass));
+        RRSet[] storage sets = rrsets[node];
+        uint index = indexes[node][key];
+
+        if (rdata.length == 0) {
+            if (index == 0) {
+                return;
+            }
+            // Move the last RRset into the gap left by this one
+            if (index != sets.length) {
+                RRSet storage last = sets[sets.length - 1];
+                sets[index - 1] = last;
+                indexes[node][last.key] = index;
+            }
+            sets.pop();
+            delete indexes[node][key];
+        } else if (index == 0) {
+            sets.push(RRSet(key, rdata));
+            index = sets.length;
+            indexes[node][key] = index;
+        } else {
+            sets[index - 1].rdata = rdata;
+        }
+        emit DnsrrChanged(node, qtype, qclass, uint32(index - 1));
+    }
+}
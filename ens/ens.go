@@ -14,17 +14,27 @@
 // along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
 
 //go:generate abigen --sol contract/ens.sol --pkg contract --out contract/ens.go
+//go:generate go run contract/generate.go -sol contract/resolvers.sol -out contract/resolvers.go
 
 package ens
 
 import (
+    "errors"
+    "fmt"
+    "math/big"
     "strings"
 
     "github.com/arachnid/ensdns/ens/contract"
     "github.com/miekg/dns"
+    "github.com/ethereum/go-ethereum"
+    "github.com/ethereum/go-ethereum/accounts/abi"
     "github.com/ethereum/go-ethereum/accounts/abi/bind"
     "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/core/types"
     "github.com/ethereum/go-ethereum/crypto"
+    "github.com/ethereum/go-ethereum/rpc"
+    "golang.org/x/net/context"
 )
 
 func NameHash(name string) common.Hash {
@@ -41,7 +51,30 @@ func NameHash(name string) common.Hash {
     return crypto.Keccak256Hash(parent[:], label[:])
 }
 
+// blockBackend wraps a contract backend, executing all calls against the state
+// at a fixed block rather than the latest one.
+type blockBackend struct {
+    bind.ContractBackend
+    block *big.Int
+}
+
+func (b *blockBackend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+    return b.ContractBackend.CodeAt(ctx, contract, b.block)
+}
+
+func (b *blockBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+    return b.ContractBackend.CallContract(ctx, call, b.block)
+}
+
+// AtBlock returns a backend that reads contract state as of the specified block.
+// Registries and resolvers constructed with it are unaffected by reorgs of later
+// blocks.
+func AtBlock(backend bind.ContractBackend, block *big.Int) bind.ContractBackend {
+    return &blockBackend{backend, block}
+}
+
 type Registry struct {
+    Address common.Address
     backend bind.ContractBackend
     ens *contract.ENSSession
 }
@@ -53,6 +86,7 @@ func New(backend bind.ContractBackend, registryAddress common.Address, opts bind
     }
 
     return &Registry{
+        Address: registryAddress,
         backend: backend,
         ens: &contract.ENSSession{
             Contract:     ens,
@@ -63,17 +97,99 @@ func New(backend bind.ContractBackend, registryAddress common.Address, opts bind
 
 
 func (reg *Registry) GetResolver(name string) (*Resolver, error) {
+    return reg.GetResolverContext(context.Background(), name)
+}
+
+// GetResolverContext is like GetResolver, but gives up when ctx is cancelled or
+// its deadline passes.
+func (reg *Registry) GetResolverContext(ctx context.Context, name string) (*Resolver, error) {
     node := NameHash(name)
-    resolverAddr, err := reg.ens.Resolver(node)
+    opts := reg.ens.CallOpts
+    opts.Context = ctx
+    resolverAddr, err := reg.ens.Contract.Resolver(&opts, node)
     if err != nil {
         return nil, err
     }
 
+    return reg.newResolver(node, resolverAddr)
+}
+
+func (reg *Registry) GetOwner(name string) (common.Address, error) {
+    return reg.GetOwnerContext(context.Background(), name)
+}
+
+// GetOwnerContext is like GetOwner, but gives up when ctx is cancelled or its
+// deadline passes.
+func (reg *Registry) GetOwnerContext(ctx context.Context, name string) (common.Address, error) {
+    opts := reg.ens.CallOpts
+    opts.Context = ctx
+    return reg.ens.Contract.Owner(&opts, NameHash(name))
+}
+
+// SetOwner transfers ownership of name to owner.
+func (reg *Registry) SetOwner(name string, owner common.Address) (*types.Transaction, error) {
+    return reg.SetOwnerContext(context.Background(), name, owner)
+}
+
+// SetOwnerContext is like SetOwner, but gives up when ctx is cancelled or its
+// deadline passes.
+func (reg *Registry) SetOwnerContext(ctx context.Context, name string, owner common.Address) (*types.Transaction, error) {
+    opts := reg.ens.TransactOpts
+    opts.Context = ctx
+    return reg.ens.Contract.SetOwner(&opts, NameHash(name), owner)
+}
+
+// SetSubnodeOwner makes owner the owner of label.name.
+func (reg *Registry) SetSubnodeOwner(name, label string, owner common.Address) (*types.Transaction, error) {
+    return reg.SetSubnodeOwnerContext(context.Background(), name, label, owner)
+}
+
+// SetSubnodeOwnerContext is like SetSubnodeOwner, but gives up when ctx is
+// cancelled or its deadline passes.
+func (reg *Registry) SetSubnodeOwnerContext(ctx context.Context, name, label string, owner common.Address) (*types.Transaction, error) {
+    opts := reg.ens.TransactOpts
+    opts.Context = ctx
+    return reg.ens.Contract.SetSubnodeOwner(&opts, NameHash(name), crypto.Keccak256Hash([]byte(label)), owner)
+}
+
+// SetResolver sets the address of the resolver for name.
+func (reg *Registry) SetResolver(name string, resolver common.Address) (*types.Transaction, error) {
+    return reg.SetResolverContext(context.Background(), name, resolver)
+}
+
+// SetResolverContext is like SetResolver, but gives up when ctx is cancelled or
+// its deadline passes.
+func (reg *Registry) SetResolverContext(ctx context.Context, name string, resolver common.Address) (*types.Transaction, error) {
+    opts := reg.ens.TransactOpts
+    opts.Context = ctx
+    return reg.ens.Contract.SetResolver(&opts, NameHash(name), resolver)
+}
+
+// SetTTL sets the time in seconds for which name's registry entry and records
+// may be cached.
+func (reg *Registry) SetTTL(name string, ttl uint64) (*types.Transaction, error) {
+    return reg.SetTTLContext(context.Background(), name, ttl)
+}
+
+// SetTTLContext is like SetTTL, but gives up when ctx is cancelled or its
+// deadline passes.
+func (reg *Registry) SetTTLContext(ctx context.Context, name string, ttl uint64) (*types.Transaction, error) {
+    opts := reg.ens.TransactOpts
+    opts.Context = ctx
+    return reg.ens.Contract.SetTTL(&opts, NameHash(name), ttl)
+}
+
+func (reg *Registry) newResolver(node common.Hash, resolverAddr common.Address) (*Resolver, error) {
     resolver, err := contract.NewResolver(resolverAddr, reg.backend)
     if err != nil {
         return nil, err
     }
 
+    rrsets, err := contract.NewRRSetResolver(resolverAddr, reg.backend)
+    if err != nil {
+        return nil, err
+    }
+
     return &Resolver{
         Address: resolverAddr,
         node: node,
@@ -82,6 +198,10 @@ func (reg *Registry) GetResolver(name string) (*Resolver, error) {
             Contract:     resolver,
             TransactOpts: reg.ens.TransactOpts,
         },
+        rrsets: &contract.RRSetResolverSession{
+            Contract:     rrsets,
+            TransactOpts: reg.ens.TransactOpts,
+        },
     }, nil
 }
 
@@ -90,14 +210,37 @@ type Resolver struct {
     node common.Hash
     registry *Registry
     resolver *contract.ResolverSession
+    rrsets *contract.RRSetResolverSession
 }
 
 func (res *Resolver) GetRRs() (rrs []dns.RR, err error) {
-    rdata, err := res.resolver.Dnsrr(res.node)
+    return res.GetRRsContext(context.Background())
+}
+
+// GetRRsContext is like GetRRs, but gives up when ctx is cancelled or its
+// deadline passes.
+func (res *Resolver) GetRRsContext(ctx context.Context) (rrs []dns.RR, err error) {
+    // Resolvers that don't implement supportsInterface are treated as storing a single blob
+    if supported, err := res.SupportsRRSets(ctx); err == nil && supported {
+        return res.getRRSets(ctx)
+    }
+
+    opts := res.resolver.CallOpts
+    opts.Context = ctx
+    rdata, err := res.resolver.Contract.Dnsrr(&opts, res.node)
+    if err != nil {
+        return nil, err
+    }
+
+    rrs, err = UnpackRRs(rdata)
     if err != nil {
         return nil, err
     }
+    return res.readChunks(ctx, rrs)
+}
 
+// UnpackRRs decodes a sequence of wire-format resource records, as stored by a resolver.
+func UnpackRRs(rdata []byte) (rrs []dns.RR, err error) {
     for off := 0; off < len(rdata); {
         r, off1, err := dns.UnpackRR(rdata, off)
         if err != nil {
@@ -112,7 +255,8 @@ func (res *Resolver) GetRRs() (rrs []dns.RR, err error) {
     return rrs, nil
 }
 
-func packRRs(rrs []dns.RR) (rdata []byte, err error) {
+// PackRRs encodes resource records in the wire format stored by a resolver.
+func PackRRs(rrs []dns.RR) (rdata []byte, err error) {
     len := (&dns.Msg{Answer: rrs}).Len()
 
     rdata = make([]byte, len)
@@ -128,16 +272,326 @@ func packRRs(rrs []dns.RR) (rdata []byte, err error) {
     return rdata[:off], nil
 }
 
-func (res *Resolver) SetRRs(rrs []dns.RR) error {
-    rdata, err := packRRs(rrs)
+func (res *Resolver) SetRRs(rrs []dns.RR) ([]*types.Transaction, error) {
+    return res.SetRRsContext(context.Background(), rrs)
+}
+
+// SetRRsContext is like SetRRs, but gives up when ctx is cancelled or its
+// deadline passes.
+//
+// Zones larger than MaxChunkSize are written in several transactions, after
+// claiming ownership of any subnodes the extra chunks are stored under. The
+// claims aren't waited for, so writes to newly claimed subnodes are sent with an
+// upper bound on the gas they need, unless the options set a gas limit. The
+// transactions are mined independently, so readers may see a mix of old and new
+// chunks until they all are. All the transactions are returned in the order
+// they were sent, including those sent before any error.
+func (res *Resolver) SetRRsContext(ctx context.Context, rrs []dns.RR) ([]*types.Transaction, error) {
+    chunks, err := packChunks(rrs, MaxChunkSize)
+    if err != nil {
+        return nil, err
+    }
+
+    unclaimed, err := res.unclaimedChunks(ctx, len(chunks))
+    if err != nil {
+        return nil, err
+    }
+    txs, err := res.claimChunks(ctx, unclaimed)
+    if err != nil {
+        return txs, err
+    }
+    claiming := make(map[int]bool)
+    for _, i := range unclaimed {
+        claiming[i] = true
+    }
+
+    // Write the first chunk last, so readers never see a marker for chunks
+    // that haven't been stored yet.
+    for i := len(chunks) - 1; i >= 0; i-- {
+        opts := res.resolver.TransactOpts
+        opts.Context = ctx
+        if claiming[i] && opts.GasLimit == nil {
+            if opts.GasLimit, err = res.estimateUnclaimed(ctx, chunks[i]); err != nil {
+                return txs, err
+            }
+        }
+        tx, err := res.resolver.Contract.SetDnsrr(&opts, res.chunkNode(i), chunks[i])
+        if err != nil {
+            return txs, err
+        }
+        txs = append(txs, tx)
+    }
+    return txs, nil
+}
+
+// CountSetRRs returns the number of chunks SetRRs would store rrs in, and how
+// many of the subnodes those chunks are stored under it would first have to
+// claim. Each chunk and each claim takes a transaction.
+func (res *Resolver) CountSetRRs(ctx context.Context, rrs []dns.RR) (chunks, claims int, err error) {
+    packed, err := packChunks(rrs, MaxChunkSize)
+    if err != nil {
+        return 0, 0, err
+    }
+    unclaimed, err := res.unclaimedChunks(ctx, len(packed))
+    if err != nil {
+        return 0, 0, err
+    }
+    return len(packed), len(unclaimed), nil
+}
+
+// EstimateSetRRs returns the gas SetRRs would use to store rrs, summed over all
+// the transactions needed, including those claiming subnodes for chunks. Writes
+// to subnodes that are yet to be claimed count the upper bound SetRRs sends them
+// with.
+func (res *Resolver) EstimateSetRRs(ctx context.Context, rrs []dns.RR) (*big.Int, error) {
+    chunks, err := packChunks(rrs, MaxChunkSize)
+    if err != nil {
+        return nil, err
+    }
+    unclaimed, err := res.unclaimedChunks(ctx, len(chunks))
+    if err != nil {
+        return nil, err
+    }
+
+    total := new(big.Int)
+    claiming := make(map[int]bool)
+    for _, i := range unclaimed {
+        reg := res.registry
+        gas, err := reg.estimate(ctx, reg.Address, ensABI, "setSubnodeOwner", res.node, chunkLabelHash(i), reg.ens.TransactOpts.From)
+        if err != nil {
+            return nil, err
+        }
+        total.Add(total, gas)
+        claiming[i] = true
+    }
+
+    for i, chunk := range chunks {
+        var gas *big.Int
+        if claiming[i] {
+            gas, err = res.estimateUnclaimed(ctx, chunk)
+        } else {
+            gas, err = res.estimate(ctx, resolverABI, "setDnsrr", res.chunkNode(i), chunk)
+        }
+        if err != nil {
+            return nil, err
+        }
+        total.Add(total, gas)
+    }
+    return total, nil
+}
+
+// Authorised returns true if the account set in the registry's transaction
+// options may change the resolver's records. Resolvers don't say why a write
+// fails, so this estimates the gas needed for a write that changes nothing, with
+// ceiling as the allowance. Newer nodes report a write the resolver rejects as
+// an error, and older ones as needing the entire allowance; either means the
+// account isn't authorised.
+func (res *Resolver) Authorised(ctx context.Context, ceiling *big.Int) (bool, error) {
+    var parsed abi.ABI
+    var method string
+    var args []interface{}
+    if supported, err := res.SupportsRRSets(ctx); err == nil && supported {
+        // Deleting an RRset that doesn't exist changes nothing
+        parsed, method = rrsetResolverABI, "setRRSet"
+        args = []interface{}{res.node, common.Hash{}, uint16(dns.TypeTXT), uint16(dns.ClassINET), []byte{}}
+    } else {
+        // Rewriting the first chunk with its current contents changes nothing.
+        // Resolvers that can't return it won't accept it either.
+        opts := res.resolver.CallOpts
+        opts.Context = ctx
+        rdata, err := res.resolver.Contract.Dnsrr(&opts, res.node)
+        if err != nil {
+            rdata = []byte{}
+        }
+        parsed, method, args = resolverABI, "setDnsrr", []interface{}{res.node, rdata}
+    }
+
+    input, err := parsed.Pack(method, args...)
     if err != nil {
-        return err
+        return false, err
+    }
+    msg := ethereum.CallMsg{From: res.registry.ens.TransactOpts.From, To: &res.Address, Gas: ceiling, Data: input}
+    gas, err := res.registry.backend.EstimateGas(ctx, msg)
+    if err != nil {
+        // Only give up if we ran out of time; otherwise assume the write reverted
+        if ctx.Err() != nil {
+            return false, ctx.Err()
+        }
+        return false, nil
     }
+    return gas.Cmp(ceiling) < 0, nil
+}
+
+// estimate returns the gas needed to call method on the resolver from the
+// account set in the registry's transaction options.
+func (res *Resolver) estimate(ctx context.Context, parsed abi.ABI, method string, args ...interface{}) (*big.Int, error) {
+    return res.registry.estimate(ctx, res.Address, parsed, method, args...)
+}
 
-    _, err = res.resolver.SetDnsrr(res.node, rdata)
-    return err
+// estimate returns the gas needed to call method on the contract at to from the
+// account set in the registry's transaction options.
+func (reg *Registry) estimate(ctx context.Context, to common.Address, parsed abi.ABI, method string, args ...interface{}) (*big.Int, error) {
+    input, err := parsed.Pack(method, args...)
+    if err != nil {
+        return nil, err
+    }
+    msg := ethereum.CallMsg{From: reg.ens.TransactOpts.From, To: &to, Data: input}
+    return reg.backend.EstimateGas(ctx, msg)
 }
 
 func (res *Resolver) GetTTL() (uint64, error) {
     return res.registry.ens.Ttl(res.node)
 }
+
+// BatchCaller is implemented by backends that can send several JSON-RPC requests
+// in a single round trip.
+type BatchCaller interface {
+    BatchCallContext(ctx context.Context, b []rpc.BatchElem) error
+}
+
+var (
+    ensABI, _ = abi.JSON(strings.NewReader(contract.ENSABI))
+    resolverABI, _ = abi.JSON(strings.NewReader(contract.ResolverABI))
+    rrsetResolverABI, _ = abi.JSON(strings.NewReader(contract.RRSetResolverABI))
+)
+
+// NoResolverError is returned by Lookup for names with no resolver set.
+var NoResolverError = errors.New("No resolver set")
+
+// Record describes a name's registry entry, and the DNS records held by its resolver.
+type Record struct {
+    Owner common.Address
+    TTL uint64
+    Resolver *Resolver
+    RRs []dns.RR
+}
+
+// Lookup fetches the owner, TTL and resolver of a name, along with the DNS
+// records its resolver holds. If the backend implements BatchCaller and hint is
+// the name's current resolver, everything is retrieved in a single round trip;
+// otherwise the records are fetched once the resolver is known. Names with no
+// resolver set return NoResolverError.
+func (reg *Registry) Lookup(ctx context.Context, name string, hint common.Address) (*Record, error) {
+    backend, block := reg.backend, (*big.Int)(nil)
+    if bb, ok := backend.(*blockBackend); ok {
+        backend, block = bb.ContractBackend, bb.block
+    }
+    batcher, ok := backend.(BatchCaller)
+    if !ok {
+        return reg.lookup(ctx, name)
+    }
+
+    node := NameHash(name)
+    calls := []struct{
+        abi abi.ABI
+        to common.Address
+        method string
+        result interface{}
+        optional bool // If set, failure leaves result nil rather than failing the lookup
+    }{
+        {ensABI, reg.Address, "owner", new(common.Address), false},
+        {ensABI, reg.Address, "ttl", new(uint64), false},
+        {ensABI, reg.Address, "resolver", new(common.Address), false},
+        {resolverABI, hint, "dnsrr", new([]byte), true},
+    }
+    if hint == (common.Address{}) {
+        calls = calls[:3]
+    }
+
+    blockArg := "latest"
+    if block != nil {
+        blockArg = hexutil.EncodeBig(block)
+    }
+    batch := make([]rpc.BatchElem, len(calls))
+    outputs := make([]hexutil.Bytes, len(calls))
+    for i, call := range calls {
+        input, err := call.abi.Pack(call.method, node)
+        if err != nil {
+            return nil, err
+        }
+        args := map[string]interface{}{
+            "to": call.to,
+            "data": hexutil.Bytes(input),
+        }
+        batch[i] = rpc.BatchElem{Method: "eth_call", Args: []interface{}{args, blockArg}, Result: &outputs[i]}
+    }
+    if err := batcher.BatchCallContext(ctx, batch); err != nil {
+        return nil, err
+    }
+    for i := range calls {
+        call := &calls[i]
+        err := batch[i].Error
+        if err == nil && len(outputs[i]) == 0 {
+            err = fmt.Errorf("Empty result calling %s on %s", call.method, call.to.Hex())
+        }
+        if err == nil {
+            err = call.abi.Unpack(call.result, call.method, outputs[i])
+        }
+        if err != nil && call.optional {
+            call.result = nil
+        } else if err != nil {
+            return nil, err
+        }
+    }
+
+    resolverAddr := *calls[2].result.(*common.Address)
+    if resolverAddr == (common.Address{}) {
+        return nil, NoResolverError
+    }
+    resolver, err := reg.newResolver(node, resolverAddr)
+    if err != nil {
+        return nil, err
+    }
+
+    record := &Record{
+        Owner: *calls[0].result.(*common.Address),
+        TTL: *calls[1].result.(*uint64),
+        Resolver: resolver,
+    }
+    if len(calls) > 3 && resolverAddr == hint && calls[3].result != nil {
+        record.RRs, err = UnpackRRs(*calls[3].result.(*[]byte))
+        if err == nil {
+            record.RRs, err = resolver.readChunks(ctx, record.RRs)
+        }
+    } else {
+        // Our guess at the resolver was wrong, or it couldn't return the
+        // records as a single blob, so fetch them separately
+        record.RRs, err = resolver.GetRRsContext(ctx)
+    }
+    if err != nil {
+        return nil, err
+    }
+    return record, nil
+}
+
+// lookup implements Lookup for backends that don't support batching.
+func (reg *Registry) lookup(ctx context.Context, name string) (*Record, error) {
+    node := NameHash(name)
+    opts := reg.ens.CallOpts
+    opts.Context = ctx
+
+    owner, err := reg.ens.Contract.Owner(&opts, node)
+    if err != nil {
+        return nil, err
+    }
+
+    ttl, err := reg.ens.Contract.Ttl(&opts, node)
+    if err != nil {
+        return nil, err
+    }
+
+    resolver, err := reg.GetResolverContext(ctx, name)
+    if err != nil {
+        return nil, err
+    }
+    if resolver.Address == (common.Address{}) {
+        return nil, NoResolverError
+    }
+
+    rrs, err := resolver.GetRRsContext(ctx)
+    if err != nil {
+        return nil, err
+    }
+
+    return &Record{Owner: owner, TTL: ttl, Resolver: resolver, RRs: rrs}, nil
+}
@@ -0,0 +1,212 @@
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package ens
+
+import (
+    "fmt"
+    "math/big"
+    "strings"
+    "testing"
+
+    "github.com/miekg/dns"
+    "github.com/ethereum/go-ethereum"
+    "github.com/ethereum/go-ethereum/accounts/abi/bind"
+    "github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/common/hexutil"
+    "github.com/ethereum/go-ethereum/core"
+    "github.com/ethereum/go-ethereum/crypto"
+    "github.com/ethereum/go-ethereum/rpc"
+    "golang.org/x/net/context"
+)
+
+// batchBackend makes the simulated backend a BatchCaller, so tests exercise the
+// batched Lookup used with real nodes. Each eth_call in a batch is executed in
+// turn.
+type batchBackend struct {
+    *backends.SimulatedBackend
+}
+
+func (b batchBackend) BatchCallContext(ctx context.Context, batch []rpc.BatchElem) error {
+    for i := range batch {
+        elem := &batch[i]
+        if elem.Method != "eth_call" {
+            elem.Error = fmt.Errorf("Unsupported method %s", elem.Method)
+            continue
+        }
+        args := elem.Args[0].(map[string]interface{})
+        to := args["to"].(common.Address)
+        msg := ethereum.CallMsg{To: &to, Data: args["data"].(hexutil.Bytes)}
+        var block *big.Int
+        if arg := elem.Args[1].(string); arg != "latest" {
+            if block, elem.Error = hexutil.DecodeBig(arg); elem.Error != nil {
+                continue
+            }
+        }
+        out, err := b.CallContract(ctx, msg, block)
+        if err != nil {
+            elem.Error = err
+            continue
+        }
+        *elem.Result.(*hexutil.Bytes) = out
+    }
+    return nil
+}
+
+// testENS is a registry and DNS resolver deployed to a simulated chain, with an
+// account that owns the root node.
+type testENS struct {
+    t *testing.T
+    sim *backends.SimulatedBackend
+    auth *bind.TransactOpts
+    registry *Registry
+    resolver common.Address
+}
+
+// newTestENS deploys a registry and DNS resolver. If batch is set, the registry
+// uses a backend that supports batched calls.
+func newTestENS(t *testing.T, batch bool) *testENS {
+    key, err := crypto.GenerateKey()
+    if err != nil {
+        t.Fatalf("Error generating key: %s", err)
+    }
+    auth := bind.NewKeyedTransactor(key)
+    sim := backends.NewSimulatedBackend(core.GenesisAccount{Address: auth.From, Balance: big.NewInt(1e18)})
+    te := &testENS{t: t, sim: sim, auth: auth}
+
+    registryAddress, _, err := DeployRegistry(auth, sim)
+    if err != nil {
+        t.Fatalf("Error deploying registry: %s", err)
+    }
+    sim.Commit()
+    te.resolver, _, err = DeployDNSResolver(auth, sim, registryAddress)
+    if err != nil {
+        t.Fatalf("Error deploying DNS resolver: %s", err)
+    }
+    sim.Commit()
+
+    var backend bind.ContractBackend = sim
+    if batch {
+        backend = batchBackend{sim}
+    }
+    te.registry, err = New(backend, registryAddress, *auth)
+    if err != nil {
+        t.Fatalf("Error constructing ENS instance: %s", err)
+    }
+    return te
+}
+
+// register makes the test account the owner of name, and sets its resolver
+// unless resolver is zero.
+func (te *testENS) register(name string, resolver common.Address) {
+    labels := dns.SplitDomainName(name)
+    parent := ""
+    for i := len(labels) - 1; i >= 0; i-- {
+        if _, err := te.registry.SetSubnodeOwner(parent, labels[i], te.auth.From); err != nil {
+            te.t.Fatalf("Error registering %s: %s", name, err)
+        }
+        te.sim.Commit()
+        parent = dns.Fqdn(strings.Join(labels[i:], "."))
+    }
+
+    if resolver == (common.Address{}) {
+        return
+    }
+    if _, err := te.registry.SetResolver(name, resolver); err != nil {
+        te.t.Fatalf("Error setting resolver for %s: %s", name, err)
+    }
+    te.sim.Commit()
+}
+
+// setRRs stores the records in zone, given in zonefile format, as the records
+// for name.
+func (te *testENS) setRRs(name string, zone ...string) []dns.RR {
+    rrs := parseRRs(te.t, zone...)
+    resolver, err := te.registry.GetResolver(name)
+    if err != nil {
+        te.t.Fatalf("Error getting resolver for %s: %s", name, err)
+    }
+    if _, err := resolver.SetRRs(rrs); err != nil {
+        te.t.Fatalf("Error setting RRs for %s: %s", name, err)
+    }
+    te.sim.Commit()
+    return rrs
+}
+
+func parseRRs(t *testing.T, zone ...string) []dns.RR {
+    var rrs []dns.RR
+    for _, s := range zone {
+        rr, err := dns.NewRR(s)
+        if err != nil {
+            t.Fatalf("Error parsing %q: %s", s, err)
+        }
+        rrs = append(rrs, rr)
+    }
+    return rrs
+}
+
+// checkRRs checks that got and want hold the same records in the same order.
+func checkRRs(t *testing.T, got, want []dns.RR) {
+    if len(got) != len(want) {
+        t.Errorf("Got %d records, want %d: %v", len(got), len(want), got)
+        return
+    }
+    for i := range got {
+        if got[i].String() != want[i].String() {
+            t.Errorf("Record %d: got %v, want %v", i, got[i], want[i])
+        }
+    }
+}
+
+func TestLookup(t *testing.T) {
+    for _, batch := range []bool{false, true} {
+        te := newTestENS(t, batch)
+        te.register("example.", te.resolver)
+        want := te.setRRs("example.", "example. 300 IN SOA ns.example. hostmaster.example. 1 3600 60 3600 60", "example. 300 IN A 192.0.2.1")
+
+        // A correct hint, none, and one for a resolver the name doesn't use
+        for _, hint := range []common.Address{te.resolver, common.Address{}, common.HexToAddress("0x1234")} {
+            record, err := te.registry.Lookup(context.Background(), "example.", hint)
+            if err != nil {
+                t.Errorf("batch=%v hint=%s: Error looking up name: %s", batch, hint.Hex(), err)
+                continue
+            }
+            if record.Owner != te.auth.From {
+                t.Errorf("batch=%v hint=%s: Got owner %s, want %s", batch, hint.Hex(), record.Owner.Hex(), te.auth.From.Hex())
+            }
+            if record.Resolver.Address != te.resolver {
+                t.Errorf("batch=%v hint=%s: Got resolver %s, want %s", batch, hint.Hex(), record.Resolver.Address.Hex(), te.resolver.Hex())
+            }
+            checkRRs(t, record.RRs, want)
+        }
+    }
+}
+
+func TestLookupNoResolver(t *testing.T) {
+    for _, batch := range []bool{false, true} {
+        te := newTestENS(t, batch)
+        te.register("example.", common.Address{})
+
+        // No hint, a resolver the name doesn't use, and an address that isn't
+        // a resolver
+        for _, hint := range []common.Address{common.Address{}, te.resolver, common.HexToAddress("0x1234")} {
+            _, err := te.registry.Lookup(context.Background(), "example.", hint)
+            if err != NoResolverError {
+                t.Errorf("batch=%v hint=%s: Got error %v, want NoResolverError", batch, hint.Hex(), err)
+            }
+        }
+    }
+}
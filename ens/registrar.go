@@ -0,0 +1,91 @@
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package ens
+
+import (
+    "github.com/arachnid/ensdns/ens/contract"
+    "github.com/ethereum/go-ethereum/accounts/abi/bind"
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/crypto"
+    "golang.org/x/net/context"
+)
+
+// DeployRegistry deploys a new ENS registry. The root node is owned by the
+// deploying account.
+func DeployRegistry(opts *bind.TransactOpts, backend bind.ContractBackend) (common.Address, *types.Transaction, error) {
+    addr, tx, _, err := contract.DeployENS(opts, backend)
+    return addr, tx, err
+}
+
+// DeployPublicResolver deploys a resolver that lets the owner of each name in
+// the registry set its address and content hash.
+func DeployPublicResolver(opts *bind.TransactOpts, backend bind.ContractBackend, registryAddress common.Address) (common.Address, *types.Transaction, error) {
+    addr, tx, _, err := contract.DeployPublicResolver(opts, backend, registryAddress)
+    return addr, tx, err
+}
+
+// DeployDNSResolver deploys a resolver that lets the owner of each name in the
+// registry set its DNS records, for use with Resolver.SetRRs.
+func DeployDNSResolver(opts *bind.TransactOpts, backend bind.ContractBackend, registryAddress common.Address) (common.Address, *types.Transaction, error) {
+    addr, tx, _, err := contract.DeployDNSResolver(opts, backend, registryAddress)
+    return addr, tx, err
+}
+
+// DeployRegistrar deploys a registrar that gives subdomains of name to the
+// first account to register them. The registrar can't register anything until
+// it's made the owner of name.
+func DeployRegistrar(opts *bind.TransactOpts, backend bind.ContractBackend, registryAddress common.Address, name string) (common.Address, *types.Transaction, error) {
+    addr, tx, _, err := contract.DeployFIFSRegistrar(opts, backend, registryAddress, NameHash(name))
+    return addr, tx, err
+}
+
+// Registrar is a first-in, first-served registrar, which gives subdomains of
+// the name it owns to the first account to register them.
+type Registrar struct {
+    Address common.Address
+    registrar *contract.FIFSRegistrarSession
+}
+
+func NewRegistrar(backend bind.ContractBackend, registrarAddress common.Address, opts bind.TransactOpts) (*Registrar, error) {
+    registrar, err := contract.NewFIFSRegistrar(registrarAddress, backend)
+    if err != nil {
+        return nil, err
+    }
+
+    return &Registrar{
+        Address: registrarAddress,
+        registrar: &contract.FIFSRegistrarSession{
+            Contract:     registrar,
+            TransactOpts: opts,
+        },
+    }, nil
+}
+
+// Register makes owner the owner of label under the registrar's name, and sets
+// its resolver to the registrar's public resolver. Names already registered can
+// only be re-registered by their owner.
+func (reg *Registrar) Register(label string, owner common.Address) (*types.Transaction, error) {
+    return reg.RegisterContext(context.Background(), label, owner)
+}
+
+// RegisterContext is like Register, but gives up when ctx is cancelled or its
+// deadline passes.
+func (reg *Registrar) RegisterContext(ctx context.Context, label string, owner common.Address) (*types.Transaction, error) {
+    opts := reg.registrar.TransactOpts
+    opts.Context = ctx
+    return reg.registrar.Contract.Register(&opts, crypto.Keccak256Hash([]byte(label)), owner)
+}
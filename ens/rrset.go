@@ -0,0 +1,117 @@
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package ens
+
+import (
+    "fmt"
+    "math/big"
+    "strings"
+
+    "github.com/arachnid/ensdns/ens/contract"
+    "github.com/miekg/dns"
+    "github.com/ethereum/go-ethereum/accounts/abi/bind"
+    "github.com/ethereum/go-ethereum/common"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/crypto"
+    "golang.org/x/net/context"
+)
+
+// RRSetInterfaceID is the EIP165 interface ID of resolvers implementing the
+// RRSetResolver contract, which store each RRset in a zone separately.
+var RRSetInterfaceID = [4]byte{0xb0, 0x6a, 0x74, 0x3e}
+
+// RRSetName returns the hash that identifies RRsets with the specified owner name.
+func RRSetName(name string) common.Hash {
+    return crypto.Keccak256Hash([]byte(strings.ToLower(dns.Fqdn(name))))
+}
+
+// DeployRRSetResolver deploys a resolver that stores each RRset of a name's DNS
+// records separately, so they can be changed individually with Resolver.SetRRSet.
+func DeployRRSetResolver(opts *bind.TransactOpts, backend bind.ContractBackend, registryAddress common.Address) (common.Address, *types.Transaction, error) {
+    addr, tx, _, err := contract.DeployRRSetResolver(opts, backend, registryAddress)
+    return addr, tx, err
+}
+
+// SupportsRRSets returns true if the resolver stores each RRset separately,
+// allowing them to be updated individually with SetRRSet.
+func (res *Resolver) SupportsRRSets(ctx context.Context) (bool, error) {
+    opts := res.resolver.CallOpts
+    opts.Context = ctx
+    return res.resolver.Contract.SupportsInterface(&opts, RRSetInterfaceID)
+}
+
+func (res *Resolver) getRRSets(ctx context.Context) (rrs []dns.RR, err error) {
+    opts := res.rrsets.CallOpts
+    opts.Context = ctx
+    count, err := res.rrsets.Contract.RrsetCount(&opts, res.node)
+    if err != nil {
+        return nil, err
+    }
+
+    for i := uint32(0); i < count; i++ {
+        rdata, err := res.rrsets.Contract.Rrset(&opts, res.node, i)
+        if err != nil {
+            return nil, err
+        }
+        rrset, err := UnpackRRs(rdata)
+        if err != nil {
+            return nil, err
+        }
+        rrs = append(rrs, rrset...)
+    }
+    return rrs, nil
+}
+
+// SetRRSet replaces the RRset with the specified owner name, type and class.
+// Passing no records deletes the RRset. The resolver must support RRsets; see
+// SupportsRRSets.
+func (res *Resolver) SetRRSet(name string, qtype, qclass uint16, rrs []dns.RR) (*types.Transaction, error) {
+    return res.SetRRSetContext(context.Background(), name, qtype, qclass, rrs)
+}
+
+// SetRRSetContext is like SetRRSet, but gives up when ctx is cancelled or its
+// deadline passes.
+func (res *Resolver) SetRRSetContext(ctx context.Context, name string, qtype, qclass uint16, rrs []dns.RR) (*types.Transaction, error) {
+    rdata, err := packRRSet(name, qtype, qclass, rrs)
+    if err != nil {
+        return nil, err
+    }
+
+    opts := res.rrsets.TransactOpts
+    opts.Context = ctx
+    return res.rrsets.Contract.SetRRSet(&opts, res.node, RRSetName(name), qtype, qclass, rdata)
+}
+
+// EstimateSetRRSet returns the gas SetRRSet would use to replace an RRset.
+func (res *Resolver) EstimateSetRRSet(ctx context.Context, name string, qtype, qclass uint16, rrs []dns.RR) (*big.Int, error) {
+    rdata, err := packRRSet(name, qtype, qclass, rrs)
+    if err != nil {
+        return nil, err
+    }
+
+    return res.estimate(ctx, rrsetResolverABI, "setRRSet", res.node, RRSetName(name), qtype, qclass, rdata)
+}
+
+// packRRSet packs rrs, checking they all belong to the specified RRset.
+func packRRSet(name string, qtype, qclass uint16, rrs []dns.RR) ([]byte, error) {
+    for _, rr := range rrs {
+        hdr := rr.Header()
+        if !strings.EqualFold(dns.Fqdn(hdr.Name), dns.Fqdn(name)) || hdr.Rrtype != qtype || hdr.Class != qclass {
+            return nil, fmt.Errorf("Record %v is not part of RRset %s %s %s", rr, name, dns.ClassToString[qclass], dns.TypeToString[qtype])
+        }
+    }
+    return PackRRs(rrs)
+}
@@ -0,0 +1,182 @@
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package ens
+
+import (
+    "errors"
+    "math/big"
+    "testing"
+
+    "github.com/miekg/dns"
+    "github.com/ethereum/go-ethereum"
+    "github.com/ethereum/go-ethereum/accounts/abi/bind"
+    "github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+    "github.com/ethereum/go-ethereum/core/types"
+    "github.com/ethereum/go-ethereum/crypto"
+    "golang.org/x/net/context"
+)
+
+// setRRSet replaces an RRset and mines the transaction, returning the gas it
+// used.
+func (te *testENS) setRRSet(resolver *Resolver, name string, qtype, qclass uint16, rrs []dns.RR) *big.Int {
+    tx, err := resolver.SetRRSet(name, qtype, qclass, rrs)
+    if err != nil {
+        te.t.Fatalf("Error setting RRset %s %s %s: %s", name, dns.ClassToString[qclass], dns.TypeToString[qtype], err)
+    }
+    te.sim.Commit()
+    return te.checkMined(tx)
+}
+
+// checkMined checks that tx succeeded, returning the gas it used.
+func (te *testENS) checkMined(tx *types.Transaction) *big.Int {
+    receipt, err := te.sim.TransactionReceipt(context.Background(), tx.Hash())
+    if err != nil {
+        te.t.Fatalf("Error getting receipt: %s", err)
+    }
+    if receipt.GasUsed.Cmp(tx.Gas()) >= 0 {
+        te.t.Errorf("Transaction %s failed", tx.Hash().Hex())
+    }
+    return receipt.GasUsed
+}
+
+func TestRRSetResolver(t *testing.T) {
+    te := newTestENS(t, false)
+    resolverAddr, _, err := DeployRRSetResolver(te.auth, te.sim, te.registry.Address)
+    if err != nil {
+        t.Fatalf("Error deploying RRset resolver: %s", err)
+    }
+    te.sim.Commit()
+    te.register("example.", resolverAddr)
+
+    resolver, err := te.registry.GetResolver("example.")
+    if err != nil {
+        t.Fatalf("Error getting resolver: %s", err)
+    }
+    if supported, err := resolver.SupportsRRSets(context.Background()); err != nil || !supported {
+        t.Fatalf("Resolver doesn't support RRsets: %v, %v", supported, err)
+    }
+    if ok, err := resolver.Authorised(context.Background(), big.NewInt(1000000)); err != nil || !ok {
+        t.Errorf("Owner isn't authorised to set RRsets: %v, %v", ok, err)
+    }
+
+    soa := parseRRs(t, "example. 300 IN SOA ns.example. hostmaster.example. 1 3600 60 3600 60")
+    a := parseRRs(t, "www.example. 300 IN A 192.0.2.1", "www.example. 300 IN A 192.0.2.2")
+    txt := parseRRs(t, "example. 300 IN TXT \"hello\"")
+    te.setRRSet(resolver, "example.", dns.TypeSOA, dns.ClassINET, soa)
+    te.setRRSet(resolver, "www.example.", dns.TypeA, dns.ClassINET, a)
+    te.setRRSet(resolver, "example.", dns.TypeTXT, dns.ClassINET, txt)
+
+    check := func(want ...[]dns.RR) {
+        var all []dns.RR
+        for _, rrs := range want {
+            all = append(all, rrs...)
+        }
+        got, err := resolver.GetRRs()
+        if err != nil {
+            t.Fatalf("Error getting RRs: %s", err)
+        }
+        checkRRs(t, got, all)
+    }
+    check(soa, a, txt)
+
+    // Replacing an RRset keeps its place
+    a = parseRRs(t, "WWW.example. 300 IN A 192.0.2.3")
+    estimate, err := resolver.EstimateSetRRSet(context.Background(), "www.example.", dns.TypeA, dns.ClassINET, a)
+    if err != nil {
+        t.Fatalf("Error estimating gas: %s", err)
+    }
+    if used := te.setRRSet(resolver, "www.example", dns.TypeA, dns.ClassINET, a); estimate.Cmp(used) < 0 {
+        t.Errorf("Estimated %v gas, but used %v", estimate, used)
+    }
+    check(soa, a, txt)
+
+    // Deleting an RRset moves the last one into its place
+    te.setRRSet(resolver, "example.", dns.TypeSOA, dns.ClassINET, nil)
+    check(txt, a)
+    // Deleting one that doesn't exist does nothing
+    te.setRRSet(resolver, "example.", dns.TypeA, dns.ClassINET, nil)
+    check(txt, a)
+    te.setRRSet(resolver, "www.example.", dns.TypeA, dns.ClassINET, nil)
+    check(txt)
+
+    if _, err := resolver.SetRRSet("example.", dns.TypeA, dns.ClassINET, a); err == nil {
+        t.Errorf("Expected an error setting records in the wrong RRset")
+    }
+    if _, err := resolver.SetRRSet("www.example.", dns.TypeA, dns.ClassCHAOS, a); err == nil {
+        t.Errorf("Expected an error setting records in an RRset of the wrong class")
+    }
+
+    // RRsets of other classes are distinct
+    chaos := parseRRs(t, "example. 300 CH TXT \"chaos\"")
+    te.setRRSet(resolver, "example.", dns.TypeTXT, dns.ClassCHAOS, chaos)
+    check(txt, chaos)
+    te.setRRSet(resolver, "example.", dns.TypeTXT, dns.ClassCHAOS, nil)
+    check(txt)
+
+    // Lookups fall back to fetching RRsets individually
+    record, err := te.registry.Lookup(context.Background(), "example.", resolverAddr)
+    if err != nil {
+        t.Fatalf("Error looking up name: %s", err)
+    }
+    checkRRs(t, record.RRs, txt)
+}
+
+func TestRRSetResolverUnauthorised(t *testing.T) {
+    te := newTestENS(t, false)
+    resolverAddr, _, err := DeployRRSetResolver(te.auth, te.sim, te.registry.Address)
+    if err != nil {
+        t.Fatalf("Error deploying RRset resolver: %s", err)
+    }
+    te.sim.Commit()
+    te.register("example.", resolverAddr)
+
+    key, err := crypto.GenerateKey()
+    if err != nil {
+        t.Fatalf("Error generating key: %s", err)
+    }
+    // Newer nodes report writes that revert as errors rather than estimating
+    // the whole allowance
+    for _, backend := range []bind.ContractBackend{te.sim, revertingBackend{te.sim}} {
+        other, err := New(backend, te.registry.Address, *bind.NewKeyedTransactor(key))
+        if err != nil {
+            t.Fatalf("Error constructing ENS instance: %s", err)
+        }
+        resolver, err := other.GetResolver("example.")
+        if err != nil {
+            t.Fatalf("Error getting resolver: %s", err)
+        }
+        if resolver.Address != resolverAddr {
+            t.Fatalf("Got resolver %s, want %s", resolver.Address.Hex(), resolverAddr.Hex())
+        }
+        if ok, err := resolver.Authorised(context.Background(), big.NewInt(1000000)); err != nil || ok {
+            t.Errorf("%T: Account that doesn't own the name is authorised to set RRsets: %v, %v", backend, ok, err)
+        }
+    }
+}
+
+// revertingBackend reports calls that fail at every allowance as errors when
+// estimating gas, as newer nodes do.
+type revertingBackend struct {
+    *backends.SimulatedBackend
+}
+
+func (b revertingBackend) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (*big.Int, error) {
+    gas, err := b.SimulatedBackend.EstimateGas(ctx, msg)
+    if err == nil && msg.Gas != nil && gas.Cmp(msg.Gas) >= 0 {
+        return nil, errors.New("execution reverted")
+    }
+    return gas, err
+}
@@ -0,0 +1,292 @@
+// Copyright 2016 Nick Johnson <arachnid@notdot.net>
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// Package failover provides an Ethereum client that spreads calls over several
+// RPC endpoints, skipping endpoints that fail or time out.
+package failover
+
+import (
+	"errors"
+	"log"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"golang.org/x/net/context"
+)
+
+var NoEndpointsError = errors.New("No Ethereum endpoints configured")
+
+// rpcError is implemented by errors returned by a node in response to a request,
+// as opposed to failures to reach it.
+type rpcError interface {
+	ErrorCode() int
+}
+
+type endpoint struct {
+	url string
+
+	lock    sync.Mutex
+	rpc     *rpc.Client
+	healthy bool
+}
+
+// get returns the endpoint's client, dialing it if it isn't connected.
+func (ep *endpoint) get() (*rpc.Client, error) {
+	ep.lock.Lock()
+	defer ep.lock.Unlock()
+
+	if ep.rpc == nil {
+		c, err := rpc.Dial(ep.url)
+		if err != nil {
+			ep.healthy = false
+			return nil, err
+		}
+		ep.rpc = c
+	}
+	return ep.rpc, nil
+}
+
+func (ep *endpoint) isHealthy() bool {
+	ep.lock.Lock()
+	defer ep.lock.Unlock()
+	return ep.healthy
+}
+
+func (ep *endpoint) setHealthy(healthy bool) {
+	ep.lock.Lock()
+	defer ep.lock.Unlock()
+	if ep.healthy != healthy {
+		log.Printf("Ethereum endpoint %s is now healthy=%v", ep.url, healthy)
+	}
+	ep.healthy = healthy
+}
+
+// Client is an Ethereum client backed by several RPC endpoints. Calls are sent
+// to healthy endpoints in round-robin order, and retried on the next endpoint if
+// one fails to respond. Endpoints may be any URL accepted by rpc.Dial: HTTP,
+// WebSocket or an IPC path.
+type Client struct {
+	endpoints []*endpoint
+	timeout   time.Duration
+	next      uint32
+	quit      chan struct{}
+}
+
+// Dial creates a client for the provided endpoints. Each attempt to call an
+// endpoint is limited to timeout. Endpoints that can't be reached are retried
+// when next used.
+func Dial(urls []string, timeout time.Duration) (*Client, error) {
+	if len(urls) == 0 {
+		return nil, NoEndpointsError
+	}
+
+	c := &Client{
+		timeout: timeout,
+		quit:    make(chan struct{}),
+	}
+	for _, url := range urls {
+		ep := &endpoint{url: url, healthy: true}
+		if _, err := ep.get(); err != nil {
+			log.Printf("Error connecting to Ethereum endpoint %s: %v", url, err)
+		}
+		c.endpoints = append(c.endpoints, ep)
+	}
+	return c, nil
+}
+
+// CheckHealth starts polling each endpoint's latest block number every interval,
+// so failed endpoints are avoided, and recovered ones returned to service,
+// without waiting for a query to find out.
+func (c *Client) CheckHealth(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				for _, ep := range c.endpoints {
+					go c.checkEndpoint(ep)
+				}
+			case <-c.quit:
+				return
+			}
+		}
+	}()
+}
+
+func (c *Client) checkEndpoint(ep *endpoint) {
+	client, err := ep.get()
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		_, err = ethclient.NewClient(client).HeaderByNumber(ctx, nil)
+		cancel()
+	}
+	ep.setHealthy(err == nil)
+}
+
+// Close stops health checks and disconnects from all endpoints.
+func (c *Client) Close() {
+	close(c.quit)
+	for _, ep := range c.endpoints {
+		ep.lock.Lock()
+		if ep.rpc != nil {
+			ep.rpc.Close()
+		}
+		ep.lock.Unlock()
+	}
+}
+
+// order returns the endpoints in the order they should be tried: healthy ones
+// first, starting from the next in the rotation if rotate is set, followed by
+// unhealthy ones as a last resort.
+func (c *Client) order(rotate bool) []*endpoint {
+	start := 0
+	if rotate {
+		start = int(atomic.AddUint32(&c.next, 1) % uint32(len(c.endpoints)))
+	}
+
+	healthy := make([]*endpoint, 0, len(c.endpoints))
+	var unhealthy []*endpoint
+	for i := range c.endpoints {
+		ep := c.endpoints[(start+i)%len(c.endpoints)]
+		if ep.isHealthy() {
+			healthy = append(healthy, ep)
+		} else {
+			unhealthy = append(unhealthy, ep)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// do calls fn with each endpoint in turn until one succeeds. Read-only calls
+// should set rotate to spread load; calls that change state leave it unset so
+// they consistently go to the first healthy endpoint.
+func (c *Client) do(ctx context.Context, rotate bool, fn func(context.Context, *rpc.Client) error) (err error) {
+	for _, ep := range c.order(rotate) {
+		var client *rpc.Client
+		client, err = ep.get()
+		if err != nil {
+			continue
+		}
+
+		epctx, cancel := context.WithTimeout(ctx, c.timeout)
+		err = fn(epctx, client)
+		cancel()
+
+		if _, ok := err.(rpcError); ok || err == nil || err == ethereum.NotFound {
+			// The endpoint responded, even if the answer was an error
+			ep.setHealthy(true)
+			return err
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		log.Printf("Error calling Ethereum endpoint %s: %v", ep.url, err)
+		ep.setHealthy(false)
+	}
+	return err
+}
+
+func (c *Client) HeaderByNumber(ctx context.Context, number *big.Int) (header *types.Header, err error) {
+	err = c.do(ctx, true, func(ctx context.Context, client *rpc.Client) (err error) {
+		header, err = ethclient.NewClient(client).HeaderByNumber(ctx, number)
+		return err
+	})
+	return header, err
+}
+
+func (c *Client) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) (code []byte, err error) {
+	err = c.do(ctx, true, func(ctx context.Context, client *rpc.Client) (err error) {
+		code, err = ethclient.NewClient(client).CodeAt(ctx, account, blockNumber)
+		return err
+	})
+	return code, err
+}
+
+func (c *Client) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) (ret []byte, err error) {
+	err = c.do(ctx, true, func(ctx context.Context, client *rpc.Client) (err error) {
+		ret, err = ethclient.NewClient(client).CallContract(ctx, msg, blockNumber)
+		return err
+	})
+	return ret, err
+}
+
+func (c *Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (receipt *types.Receipt, err error) {
+	err = c.do(ctx, true, func(ctx context.Context, client *rpc.Client) (err error) {
+		receipt, err = ethclient.NewClient(client).TransactionReceipt(ctx, txHash)
+		return err
+	})
+	return receipt, err
+}
+
+func (c *Client) PendingCodeAt(ctx context.Context, account common.Address) (code []byte, err error) {
+	err = c.do(ctx, false, func(ctx context.Context, client *rpc.Client) (err error) {
+		code, err = ethclient.NewClient(client).PendingCodeAt(ctx, account)
+		return err
+	})
+	return code, err
+}
+
+func (c *Client) PendingCallContract(ctx context.Context, msg ethereum.CallMsg) (ret []byte, err error) {
+	err = c.do(ctx, false, func(ctx context.Context, client *rpc.Client) (err error) {
+		ret, err = ethclient.NewClient(client).PendingCallContract(ctx, msg)
+		return err
+	})
+	return ret, err
+}
+
+func (c *Client) PendingNonceAt(ctx context.Context, account common.Address) (nonce uint64, err error) {
+	err = c.do(ctx, false, func(ctx context.Context, client *rpc.Client) (err error) {
+		nonce, err = ethclient.NewClient(client).PendingNonceAt(ctx, account)
+		return err
+	})
+	return nonce, err
+}
+
+func (c *Client) SuggestGasPrice(ctx context.Context) (price *big.Int, err error) {
+	err = c.do(ctx, false, func(ctx context.Context, client *rpc.Client) (err error) {
+		price, err = ethclient.NewClient(client).SuggestGasPrice(ctx)
+		return err
+	})
+	return price, err
+}
+
+func (c *Client) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (gas *big.Int, err error) {
+	err = c.do(ctx, false, func(ctx context.Context, client *rpc.Client) (err error) {
+		gas, err = ethclient.NewClient(client).EstimateGas(ctx, msg)
+		return err
+	})
+	return gas, err
+}
+
+func (c *Client) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	return c.do(ctx, false, func(ctx context.Context, client *rpc.Client) error {
+		return ethclient.NewClient(client).SendTransaction(ctx, tx)
+	})
+}
+
+// BatchCallContext sends all the requests in b to a single endpoint in one round trip.
+func (c *Client) BatchCallContext(ctx context.Context, b []rpc.BatchElem) error {
+	return c.do(ctx, true, func(ctx context.Context, client *rpc.Client) error {
+		return client.BatchCallContext(ctx, b)
+	})
+}
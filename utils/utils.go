@@ -26,19 +26,56 @@ import (
 
 var TimeoutError = errors.New("All DNS servers timed out")
 
+// DefaultNegativeTtl is the time in seconds for which a NotDelegatedError may be
+// cached when the authoritative response did not include an SOA record.
+var DefaultNegativeTtl uint32 = 300
+
+// NotDelegatedError is returned by FindNS when an authoritative server indicates
+// that a name is not delegated to a nameserver with the requested suffix.
+type NotDelegatedError struct {
+    Name string
+    Ttl uint32
+}
+
+func (err *NotDelegatedError) Error() string {
+    return fmt.Sprintf("%s is not delegated to a matching nameserver", err.Name)
+}
+
+// negativeTtl returns the time for which a negative response may be cached, as
+// described in RFC 2308: the lesser of the SOA record's TTL and its minimum field.
+func negativeTtl(r *dns.Msg) uint32 {
+    for _, rec := range r.Ns {
+        if soa, ok := rec.(*dns.SOA); ok {
+            if soa.Minttl < soa.Hdr.Ttl {
+                return soa.Minttl
+            }
+            return soa.Hdr.Ttl
+        }
+    }
+    return DefaultNegativeTtl
+}
+
 func FindNS(client *dns.Client, servers []string, name, nssuffix string) (*dns.NS, error) {
     query := dns.Msg{}
     query.SetQuestion(name, dns.TypeNS)
     query.RecursionDesired = false
 
     for _, server := range servers {
-        r, _, err := client.Exchange(&query, server+":53")
+        // Servers are normally named without a port, but may include one
+        addr := server
+        if _, _, err := net.SplitHostPort(server); err != nil {
+            addr = net.JoinHostPort(server, "53")
+        }
+        r, _, err := client.Exchange(&query, addr)
         if err, ok := err.(net.Error); ok && err.Timeout() {
             continue
         }
         if err != nil {
             return nil, err
         }
+        if r != nil && r.Rcode == dns.RcodeNameError {
+            return nil, &NotDelegatedError{name, negativeTtl(r)}
+        }
         if r == nil || r.Rcode != dns.RcodeSuccess {
             return nil, fmt.Errorf("Got nil or error response from NS query: %v", r)
         }
@@ -56,6 +93,11 @@ func FindNS(client *dns.Client, servers []string, name, nssuffix string) (*dns.N
         if len(subservers) > 0 {
             return FindNS(client, subservers, name, nssuffix)
         }
+
+        // An authoritative server that doesn't refer us elsewhere is the end of the road.
+        if r.Authoritative {
+            return nil, &NotDelegatedError{name, negativeTtl(r)}
+        }
     }
     return nil, TimeoutError
 }